@@ -0,0 +1,70 @@
+// Package audit provides the hooks of the authentication events, such as
+// logins, logouts, token renewals and verification failures, and a sink
+// that keeps the events as a tamper-evident audit trail.
+//
+// Set a Hook to config.Config.Hook to receive the events:
+//
+//	conf.Hook = audit.NewJSONLines(file)
+package audit
+
+import "time"
+
+// EventType is the type of the authentication event.
+type EventType string
+
+const (
+	// Login is emitted when a user logs in with core.Login and its variants.
+	Login EventType = "login"
+	// Logout is emitted when a user logs out with core.Logout.
+	Logout EventType = "logout"
+	// Renew is emitted when the token of a user is re-issued by the
+	// middleware.
+	Renew EventType = "renew"
+	// Refresh is emitted when a refresh token is exchanged for a new token
+	// pair.
+	Refresh EventType = "refresh"
+	// Revoke is emitted when a session is revoked with core.RevokeSession
+	// and its variants.
+	Revoke EventType = "revoke"
+	// VerificationFailed is emitted when a token is rejected by
+	// core.ExtractToken and its variants.
+	VerificationFailed EventType = "verification_failed"
+	// Authenticated is emitted when the middleware authenticates a request.
+	Authenticated EventType = "authenticated"
+	// Rejected is emitted when the middleware rejects a request that has a
+	// token. Note that the request with an invalid token also emits
+	// VerificationFailed before this event.
+	Rejected EventType = "rejected"
+)
+
+// Event is an authentication event. The fields that are unknown at the
+// place of the event are left empty.
+type Event struct {
+	Type EventType `json:"type"`
+	Time time.Time `json:"time"`
+	// UserID is the ID of the user.
+	UserID string `json:"user_id,omitempty"`
+	// JWTID is "jti" claim of the token.
+	JWTID string `json:"jti,omitempty"`
+	// SessionID is the ID of the server-side session.
+	SessionID string `json:"sid,omitempty"`
+	// Reason is the error message of the failure.
+	Reason string `json:"reason,omitempty"`
+	// RemoteAddr is the address of the client.
+	RemoteAddr string `json:"remote_addr,omitempty"`
+}
+
+// Hook receives the authentication events. Handle is called synchronously,
+// so it should return quickly. The error is logged, but the authentication
+// itself isn't affected.
+type Hook interface {
+	Handle(event Event) error
+}
+
+// HookFunc is an adapter to use a function as Hook.
+type HookFunc func(event Event) error
+
+// Handle implements Hook.
+func (me HookFunc) Handle(event Event) error {
+	return me(event)
+}
@@ -0,0 +1,110 @@
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+)
+
+// JSON-lines audit trail
+
+// ErrTampered is returned by Verify when the audit trail is modified,
+// reordered or truncated in the middle.
+var ErrTampered = errors.New("audit trail is tampered")
+
+// genesis is the previous hash of the first entry.
+var genesis = hex.EncodeToString(make([]byte, sha256.Size))
+
+// entry is a line of the audit trail. Hash is the SHA-256 of Prev, Seq and
+// Event, so modifying any entry breaks the chain of the following entries.
+type entry struct {
+	Seq   uint64          `json:"seq"`
+	Prev  string          `json:"prev"`
+	Event json.RawMessage `json:"event"`
+	Hash  string          `json:"hash"`
+}
+
+func (me *entry) digest() string {
+	h := sha256.New()
+	io.WriteString(h, me.Prev+"\n"+strconv.FormatUint(me.Seq, 10)+"\n")
+	h.Write(me.Event)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// JSONLines is a Hook that writes the events to an io.Writer as JSON lines.
+// Each line holds the hash of the previous line, so tampering is detected
+// by Verify. It is safe for concurrent use.
+type JSONLines struct {
+	mutex sync.Mutex
+	w     io.Writer
+	seq   uint64
+	prev  string
+}
+
+// NewJSONLines creates a new JSONLines that starts a new audit trail on w.
+func NewJSONLines(w io.Writer) *JSONLines {
+	return &JSONLines{w: w, prev: genesis}
+}
+
+// ResumeJSONLines verifies the existing audit trail read from r, and creates
+// a new JSONLines that continues it on w. Typically, r and w are the same
+// file that is opened with os.O_APPEND.
+func ResumeJSONLines(w io.Writer, r io.Reader) (*JSONLines, error) {
+	seq, prev, err := verify(r)
+	if err != nil {
+		return nil, err
+	}
+	return &JSONLines{w: w, seq: seq, prev: prev}, nil
+}
+
+// Handle implements Hook.
+func (me *JSONLines) Handle(event Event) error {
+	raw, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	line := &entry{Seq: me.seq + 1, Prev: me.prev, Event: raw}
+	line.Hash = line.digest()
+	data, err := json.Marshal(line)
+	if err != nil {
+		return err
+	}
+	if _, err = me.w.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	me.seq, me.prev = line.Seq, line.Hash
+	return nil
+}
+
+// Verify reads the audit trail from r and checks its hash chain. The error
+// wraps ErrTampered if the trail is modified.
+func Verify(r io.Reader) error {
+	_, _, err := verify(r)
+	return err
+}
+
+// verify returns the sequence number and the hash of the last entry.
+func verify(r io.Reader) (uint64, string, error) {
+	seq, prev := uint64(0), genesis
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var line entry
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			return 0, "", fmt.Errorf("%w: line %d: %v", ErrTampered, seq+1, err)
+		}
+		if line.Seq != seq+1 || line.Prev != prev || line.digest() != line.Hash {
+			return 0, "", fmt.Errorf("%w: line %d", ErrTampered, seq+1)
+		}
+		seq, prev = line.Seq, line.Hash
+	}
+	return seq, prev, scanner.Err()
+}
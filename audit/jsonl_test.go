@@ -0,0 +1,73 @@
+package audit_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hiroaki-yamamoto/gauth/audit"
+	"gotest.tools/v3/assert"
+)
+
+// JSON-lines audit trail test
+
+func writeTrail(t *testing.T, events ...audit.Event) *bytes.Buffer {
+	var buf bytes.Buffer
+	sink := audit.NewJSONLines(&buf)
+	for _, event := range events {
+		assert.NilError(t, sink.Handle(event))
+	}
+	return &buf
+}
+
+func TestJSONLines(t *testing.T) {
+	now := time.Unix(1700000000, 0).UTC()
+	events := []audit.Event{
+		{Type: audit.Login, Time: now, UserID: "alice", JWTID: "jti-1"},
+		{
+			Type: audit.VerificationFailed, Time: now.Add(time.Minute),
+			JWTID: "jti-1", Reason: "jwt is expired",
+		},
+		{Type: audit.Logout, Time: now.Add(time.Hour), UserID: "alice"},
+	}
+	buf := writeTrail(t, events...)
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	assert.Equal(t, len(lines), 3)
+	assert.Assert(t, strings.Contains(lines[0], `"user_id":"alice"`))
+	assert.NilError(t, audit.Verify(strings.NewReader(buf.String())))
+
+	t.Run("Modified entry", func(t *testing.T) {
+		tampered := strings.Replace(buf.String(), `"alice"`, `"mallory"`, 1)
+		err := audit.Verify(strings.NewReader(tampered))
+		assert.ErrorIs(t, err, audit.ErrTampered)
+		assert.ErrorContains(t, err, "line 1")
+	})
+	t.Run("Removed entry", func(t *testing.T) {
+		tampered := lines[0] + "\n" + lines[2] + "\n"
+		err := audit.Verify(strings.NewReader(tampered))
+		assert.ErrorIs(t, err, audit.ErrTampered)
+		assert.ErrorContains(t, err, "line 2")
+	})
+	t.Run("Reordered entries", func(t *testing.T) {
+		tampered := lines[1] + "\n" + lines[0] + "\n" + lines[2] + "\n"
+		err := audit.Verify(strings.NewReader(tampered))
+		assert.ErrorIs(t, err, audit.ErrTampered)
+	})
+	t.Run("Resume", func(t *testing.T) {
+		trail := bytes.NewBufferString(buf.String())
+		sink, err := audit.ResumeJSONLines(
+			trail, strings.NewReader(buf.String()),
+		)
+		assert.NilError(t, err)
+		assert.NilError(t, sink.Handle(audit.Event{Type: audit.Login, Time: now}))
+		assert.NilError(t, audit.Verify(trail))
+	})
+	t.Run("Resume tampered trail", func(t *testing.T) {
+		tampered := strings.Replace(buf.String(), `"alice"`, `"mallory"`, 1)
+		_, err := audit.ResumeJSONLines(
+			&bytes.Buffer{}, strings.NewReader(tampered),
+		)
+		assert.ErrorIs(t, err, audit.ErrTampered)
+	})
+}
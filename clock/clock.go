@@ -2,7 +2,10 @@ package clock
 
 // Clock interface, structure, and instance
 
-import "time"
+import (
+	"sync"
+	"time"
+)
 
 type (
 	// Time is an interface for time mocking
@@ -19,5 +22,43 @@ func (me DefaultTime) Now() time.Time {
 	return time.Now().UTC()
 }
 
-// Clock is an instance of clock
+// Clock is an instance of clock. This is used when config.Config.Clock is
+// nil.
+//
+// Deprecated: Set config.Config.Clock instead. Overwriting this variable
+// races with the other goroutines, e.g. the tests with t.Parallel().
 var Clock Time = DefaultTime{}
+
+// Fake is a controllable clock for the tests. The time doesn't move until
+// Advance or Set is called. Fake is safe for concurrent use.
+type Fake struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFake creates a new Fake that is set to now.
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+// Now returns the time of the clock.
+func (me *Fake) Now() time.Time {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	return me.now
+}
+
+// Advance moves the clock forward by d. d can be negative to move the clock
+// backward.
+func (me *Fake) Advance(d time.Duration) {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	me.now = me.now.Add(d)
+}
+
+// Set sets the time of the clock to now.
+func (me *Fake) Set(now time.Time) {
+	me.mu.Lock()
+	defer me.mu.Unlock()
+	me.now = now
+}
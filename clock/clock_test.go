@@ -14,3 +14,15 @@ func TestNow(t *testing.T) {
 	clock := clock.DefaultTime{}
 	assert.Equal(t, clock.Now().Unix(), time.Now().UTC().Unix())
 }
+
+func TestFake(t *testing.T) {
+	now := time.Unix(1700000000, 0).UTC()
+	fake := clock.NewFake(now)
+	assert.Equal(t, fake.Now(), now)
+	fake.Advance(time.Hour)
+	assert.Equal(t, fake.Now(), now.Add(time.Hour))
+	fake.Advance(-2 * time.Hour)
+	assert.Equal(t, fake.Now(), now.Add(-time.Hour))
+	fake.Set(now)
+	assert.Equal(t, fake.Now(), now)
+}
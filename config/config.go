@@ -2,10 +2,15 @@ package config
 
 import (
 	"errors"
+	"log/slog"
 	"net/http"
+	"slices"
 	"time"
 
 	"codeberg.org/gbrlsnchs/jwt"
+	"github.com/hiroaki-yamamoto/gauth/audit"
+	"github.com/hiroaki-yamamoto/gauth/clock"
+	"github.com/hiroaki-yamamoto/gauth/store"
 )
 
 // MiddlewareType indicates the type of middleware to be used.
@@ -16,19 +21,184 @@ const (
 	Cookie MiddlewareType = iota
 	// Header specifies header as the type of middleware.
 	Header
+	// Bearer specifies "Authorization: Bearer <token>" header (RFC 6750) as
+	// the type of middleware. Login sets the tokens to the headers in the
+	// same way as Header, so that the login endpoint can return them in
+	// its body. The middleware never re-issues the token regardless of
+	// RenewalPolicy; the clients get the new one from the refresh endpoint
+	// with the refresh token in the header that is named by
+	// Config.RefreshSessionName.
+	Bearer
+	// Session specifies server-side sessions as the type of middleware.
+	// The cookie holds an opaque session ID, and the session is kept in
+	// Config.SessionStore so that it can be revoked instantly and hold
+	// server-side data.
+	Session
 )
 
+// UsesCookie returns true if the middleware of the type reads the token
+// from the cookie.
+func (me MiddlewareType) UsesCookie() bool {
+	return me == Cookie || me == Session
+}
+
+// ErrorHandler responds to the request that failed the authentication.
+// err is the reason of the failure, e.g. core.ErrExpired.
+type ErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+
+// RenewalPolicy indicates when the middleware re-issues the access token of
+// the authenticated user.
+type RenewalPolicy int
+
+const (
+	// RenewAlways re-issues the token on every authenticated request.
+	RenewAlways RenewalPolicy = iota
+	// RenewNever never re-issues the token.
+	RenewNever
+	// RenewBeforeExpiry re-issues the token when its remaining lifetime is
+	// shorter than Config.RenewThreshold.
+	RenewBeforeExpiry
+)
+
+// DefaultRefreshExpireIn is the lifetime of refresh tokens that is used when
+// Config.RefreshExpireIn is zero.
+const DefaultRefreshExpireIn = 30 * 24 * time.Hour
+
 // Config is configuration model for ExtractToken
 type Config struct {
 	CookieConfig
 	// The name of the session. This is used as the name of the header when
 	// HeaderMiddleware / HeaderLoginRequired is used, and as the name of the
 	// cookie when CookieMiddleware / CookieLoginRequired is used.
-	SessionName               string
-	MiddlewareType            MiddlewareType
-	Signer                    jwt.Signer
+	SessionName    string
+	MiddlewareType MiddlewareType
+	Signer         jwt.Signer
+	// Verifier verifies the tokens. If this is nil, Signer is used as the
+	// verifier. Set this when the tokens are verified with the keys other
+	// than Signer, e.g. keyset.Remote in the services that only verify the
+	// tokens.
+	Verifier                  jwt.Verifier
 	Audience, Issuer, Subject string
 	ExpireIn                  time.Duration
+	// RenewalPolicy indicates when the middleware re-issues the token.
+	RenewalPolicy RenewalPolicy
+	// RenewThreshold is the remaining lifetime of the token that triggers
+	// the renewal when RenewalPolicy is RenewBeforeExpiry.
+	RenewThreshold time.Duration
+	// MaxSessionAge is the maximum age of the session since the user logged
+	// in. The re-issued and refreshed tokens never expire after that.
+	// Zero means the session can be extended forever.
+	MaxSessionAge time.Duration
+	// The name of the header / cookie that holds the refresh token.
+	// If this is empty, SessionName + "-refresh" is used.
+	RefreshName string
+	// The lifetime of refresh tokens. If this is zero,
+	// DefaultRefreshExpireIn is used.
+	RefreshExpireIn time.Duration
+	// RefreshStore keeps track of the refresh token families. This is
+	// required to issue and rotate refresh tokens.
+	RefreshStore store.RefreshStore
+	// Revoker holds the revoked tokens. If this is nil, the tokens are
+	// valid until they expire.
+	Revoker store.Revoker
+	// SessionStore keeps the server-side sessions. This is required when
+	// MiddlewareType is Session. In the other modes, the logins are recorded
+	// as the sessions if this is set, so that they can be listed and
+	// revoked.
+	SessionStore store.SessionStore
+	// Clock is used to compose and verify the tokens, and to set the
+	// expiration of the cookies. If this is nil, clock.Clock is used.
+	Clock clock.Time
+	// ErrorHandler responds to the requests that failed the authentication
+	// in LoginRequired and the refresh handler, and the ones that failed the
+	// authorization in RequireRoles and RequirePermissions. The error of the
+	// latter wraps middleware.ErrForbidden. If this is nil, the errors are
+	// responded in the style of GraphQL.
+	ErrorHandler ErrorHandler
+	// Logger logs the authentication events. If this is nil,
+	// slog.Default() is used. Set slog.New(slog.DiscardHandler) to disable
+	// logging.
+	Logger *slog.Logger
+	// Hook receives the authentication events, e.g. audit.JSONLines.
+	// If this is nil, the events are discarded.
+	Hook audit.Hook
+	// Leeway is the allowed clock skew between the nodes that compose and
+	// verify the tokens. This is applied to the check of "exp", "nbf" and
+	// "iat" claims.
+	Leeway time.Duration
+	// RoleHierarchy maps a role to the roles that it inherits. For example,
+	// {"admin": {"editor"}, "editor": {"viewer"}} lets the admins pass
+	// RequireRoles("viewer") as well.
+	RoleHierarchy map[string][]string
+	// Tenant is the ID of the tenant that the Config belongs to. If this is
+	// set, the tokens hold it in "tid" claim, and the tokens of the other
+	// tenants are rejected even if they share the signing key.
+	Tenant string
+}
+
+// Log returns Config.Logger, or slog.Default() if it is nil.
+func (c *Config) Log() *slog.Logger {
+	if c.Logger != nil {
+		return c.Logger
+	}
+	return slog.Default()
+}
+
+// Emit sends event to Config.Hook. The time of the event is set to Now().
+// The error of the hook is logged with Log().
+func (c *Config) Emit(event audit.Event) {
+	if c.Hook == nil {
+		return
+	}
+	event.Time = c.Now()
+	if err := c.Hook.Handle(event); err != nil {
+		c.Log().Error(
+			"failed to handle the authentication event",
+			slog.String("type", string(event.Type)), slog.Any("error", err),
+		)
+	}
+}
+
+// Now returns the current time of Config.Clock.
+func (c *Config) Now() time.Time {
+	if c.Clock != nil {
+		return c.Clock.Now()
+	}
+	return clock.Clock.Now()
+}
+
+// ExpandRoles returns the set of roles and the roles that they inherit
+// transitively through Config.RoleHierarchy.
+func (c *Config) ExpandRoles(roles []string) map[string]bool {
+	expanded := make(map[string]bool, len(roles))
+	pending := slices.Clone(roles)
+	for len(pending) > 0 {
+		role := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if expanded[role] {
+			continue
+		}
+		expanded[role] = true
+		pending = append(pending, c.RoleHierarchy[role]...)
+	}
+	return expanded
+}
+
+// RefreshSessionName returns the name of the header / cookie that holds
+// the refresh token.
+func (c *Config) RefreshSessionName() string {
+	if c.RefreshName != "" {
+		return c.RefreshName
+	}
+	return c.SessionName + "-refresh"
+}
+
+// RefreshLifetime returns the lifetime of refresh tokens.
+func (c *Config) RefreshLifetime() time.Duration {
+	if c.RefreshExpireIn > 0 {
+		return c.RefreshExpireIn
+	}
+	return DefaultRefreshExpireIn
 }
 
 // CookieConfig is used by Login function in the case of using cookie
@@ -42,6 +212,8 @@ type CookieConfig struct {
 
 // New creates a new Config class safely.
 // Note: If rxpireIn is 0, 3600 * time.Minute is used as a default-value.
+// New doesn't check the other fields; use NewWith to apply the secure
+// defaults and validate the Config with Validate.
 func New(
 	// Refer the comment of Config.SessionName.
 	sessionName string,
@@ -60,9 +232,13 @@ func New(
 		expireIn = 3600 * time.Minute
 	}
 	return &Config{
-		cookieConf, sessionName,
-		middlewareType, signer,
-		audience, issuer,
-		subject, expireIn,
+		CookieConfig:   cookieConf,
+		SessionName:    sessionName,
+		MiddlewareType: middlewareType,
+		Signer:         signer,
+		Audience:       audience,
+		Issuer:         issuer,
+		Subject:        subject,
+		ExpireIn:       expireIn,
 	}, nil
 }
@@ -32,14 +32,14 @@ func TestConfigNew(t *testing.T) {
 		"/", "localhost", false, true, http.SameSiteLaxMode,
 	}
 	config := &_conf.Config{
-		cookieConfig,
-		"session",
-		_conf.Header,
-		mustHS256("test"),
-		"test audience",
-		"test issuer",
-		"test subject",
-		2 * time.Hour,
+		CookieConfig:   cookieConfig,
+		SessionName:    "session",
+		MiddlewareType: _conf.Header,
+		Signer:         mustHS256("test"),
+		Audience:       "test audience",
+		Issuer:         "test issuer",
+		Subject:        "test subject",
+		ExpireIn:       2 * time.Hour,
 	}
 	newConfig, err := _conf.New(
 		config.SessionName,
@@ -108,3 +108,15 @@ func TestConfigNewWithZeroExp(t *testing.T) {
 	assert.Assert(t, config.ExpireIn != newConfig.ExpireIn)
 	assert.Equal(t, newConfig.ExpireIn, 3600*time.Minute)
 }
+
+func TestExpandRoles(t *testing.T) {
+	conf := &_conf.Config{RoleHierarchy: map[string][]string{
+		"admin":  {"editor"},
+		"editor": {"viewer", "admin"},
+	}}
+	roles := conf.ExpandRoles([]string{"admin", "guest"})
+	assert.DeepEqual(t, roles, map[string]bool{
+		"admin": true, "editor": true, "viewer": true, "guest": true,
+	})
+	assert.DeepEqual(t, conf.ExpandRoles(nil), map[string]bool{})
+}
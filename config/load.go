@@ -0,0 +1,384 @@
+package config
+
+// Loading Config from the settings
+
+import (
+	"cmp"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"codeberg.org/gbrlsnchs/jwt"
+)
+
+var (
+	// ErrRequired is returned when a required setting is missing.
+	ErrRequired = errors.New("required setting is missing")
+	// ErrConflict is returned when the settings can't be set together, e.g.
+	// a secret for EdDSA.
+	ErrConflict = errors.New("conflicting settings")
+)
+
+// FieldError describes the setting that is missing or invalid.
+type FieldError struct {
+	// Field is the name of the setting, e.g. "expire_in" or
+	// "cookie.same_site" of Settings, or the field of Config, e.g.
+	// "ExpireIn" or "CookieConfig.Secure".
+	Field string
+	// Err is the reason, e.g. ErrRequired.
+	Err error
+}
+
+func (me *FieldError) Error() string {
+	return fmt.Sprintf("config %s: %v", me.Field, me.Err)
+}
+
+// Unwrap returns FieldError.Err.
+func (me *FieldError) Unwrap() error {
+	return me.Err
+}
+
+// Settings is the serializable form of Config that is loaded from the
+// environment variables and the files. The durations are written in the
+// format of time.ParseDuration, e.g. "15m".
+type Settings struct {
+	// SessionName is Config.SessionName. This is required.
+	SessionName string `json:"session_name" yaml:"session_name" toml:"session_name"`
+	// Middleware is "cookie" (default), "header", "bearer" or "session".
+	Middleware string `json:"middleware" yaml:"middleware" toml:"middleware"`
+	// Algorithm is "HS256", "HS384", "HS512" or "EdDSA". This is required.
+	Algorithm string `json:"algorithm" yaml:"algorithm" toml:"algorithm"`
+	// Secret is the base64-encoded key of the HMAC algorithms.
+	Secret string `json:"secret" yaml:"secret" toml:"secret"`
+	// PrivateKey is the PEM-encoded PKCS #8 private key of EdDSA.
+	PrivateKey string `json:"private_key" yaml:"private_key" toml:"private_key"`
+	// PrivateKeyFile is the path of the file that holds PrivateKey.
+	PrivateKeyFile string `json:"private_key_file" yaml:"private_key_file" toml:"private_key_file"`
+	// PublicKey is the PEM-encoded PKIX public key of EdDSA. If this is set
+	// without PrivateKey, Config only verifies the tokens.
+	PublicKey string `json:"public_key" yaml:"public_key" toml:"public_key"`
+	// PublicKeyFile is the path of the file that holds PublicKey.
+	PublicKeyFile string `json:"public_key_file" yaml:"public_key_file" toml:"public_key_file"`
+
+	Audience string `json:"audience" yaml:"audience" toml:"audience"`
+	Issuer   string `json:"issuer" yaml:"issuer" toml:"issuer"`
+	Subject  string `json:"subject" yaml:"subject" toml:"subject"`
+	ExpireIn string `json:"expire_in" yaml:"expire_in" toml:"expire_in"`
+	// Renewal is "always" (default), "never" or "before_expiry".
+	Renewal         string `json:"renewal" yaml:"renewal" toml:"renewal"`
+	RenewThreshold  string `json:"renew_threshold" yaml:"renew_threshold" toml:"renew_threshold"`
+	MaxSessionAge   string `json:"max_session_age" yaml:"max_session_age" toml:"max_session_age"`
+	RefreshName     string `json:"refresh_name" yaml:"refresh_name" toml:"refresh_name"`
+	RefreshExpireIn string `json:"refresh_expire_in" yaml:"refresh_expire_in" toml:"refresh_expire_in"`
+	Leeway          string `json:"leeway" yaml:"leeway" toml:"leeway"`
+	Tenant          string `json:"tenant" yaml:"tenant" toml:"tenant"`
+
+	Cookie CookieSettings `json:"cookie" yaml:"cookie" toml:"cookie"`
+}
+
+// CookieSettings is the serializable form of CookieConfig. The unset
+// settings default to the secure ones as well as NewWith.
+type CookieSettings struct {
+	// Path is "/" by default.
+	Path   string `json:"path" yaml:"path" toml:"path"`
+	Domain string `json:"domain" yaml:"domain" toml:"domain"`
+	// Secure is true by default.
+	Secure *bool `json:"secure" yaml:"secure" toml:"secure"`
+	// HTTPOnly is true by default.
+	HTTPOnly *bool `json:"http_only" yaml:"http_only" toml:"http_only"`
+	// SameSite is "default", "lax", "strict" or "none". The empty string
+	// leaves the attribute unset.
+	SameSite string `json:"same_site" yaml:"same_site" toml:"same_site"`
+}
+
+var middlewareTypes = map[string]MiddlewareType{
+	"cookie": Cookie, "header": Header, "bearer": Bearer, "session": Session,
+}
+
+var renewalPolicies = map[string]RenewalPolicy{
+	"always": RenewAlways, "never": RenewNever,
+	"before_expiry": RenewBeforeExpiry,
+}
+
+var sameSiteModes = map[string]http.SameSite{
+	"default": http.SameSiteDefaultMode, "lax": http.SameSiteLaxMode,
+	"strict": http.SameSiteStrictMode, "none": http.SameSiteNoneMode,
+}
+
+// Build creates Config from the settings. All the invalid settings are
+// reported at once as FieldError joined by errors.Join. Build runs the checks
+// of Config.Validate except the stores, e.g. Config.SessionStore, because
+// they are set after it; call Validate when they are set.
+func (me *Settings) Build() (*Config, error) {
+	var errs []error
+	fail := func(field string, err error) {
+		errs = append(errs, &FieldError{Field: field, Err: err})
+	}
+	duration := func(field, value string) time.Duration {
+		if value == "" {
+			return 0
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			fail(field, err)
+		} else if d < 0 {
+			fail(field, fmt.Errorf("negative duration %s", value))
+		}
+		return d
+	}
+	option := func(field, value string, options []string) bool {
+		for _, option := range options {
+			if strings.EqualFold(value, option) {
+				return true
+			}
+		}
+		fail(field, fmt.Errorf(
+			"unknown value %q, must be one of %s", value,
+			strings.Join(options, ", "),
+		))
+		return false
+	}
+
+	if me.SessionName == "" {
+		fail("session_name", ErrRequired)
+	}
+	middlewareType := Cookie
+	if me.Middleware != "" &&
+		option("middleware", me.Middleware, keysOf(middlewareTypes)) {
+		middlewareType = middlewareTypes[strings.ToLower(me.Middleware)]
+	}
+	renewal := RenewAlways
+	if me.Renewal != "" &&
+		option("renewal", me.Renewal, keysOf(renewalPolicies)) {
+		renewal = renewalPolicies[strings.ToLower(me.Renewal)]
+	}
+	var sameSite http.SameSite
+	if mode := me.Cookie.SameSite; mode != "" &&
+		option("cookie.same_site", mode, keysOf(sameSiteModes)) {
+		sameSite = sameSiteModes[strings.ToLower(mode)]
+	}
+	expireIn := duration("expire_in", me.ExpireIn)
+	renewThreshold := duration("renew_threshold", me.RenewThreshold)
+	maxSessionAge := duration("max_session_age", me.MaxSessionAge)
+	refreshExpireIn := duration("refresh_expire_in", me.RefreshExpireIn)
+	leeway := duration("leeway", me.Leeway)
+	if renewal == RenewBeforeExpiry && renewThreshold == 0 {
+		fail("renew_threshold", fmt.Errorf(
+			"%w: renewal %q needs the threshold", ErrRequired, me.Renewal,
+		))
+	}
+	signer, verifier, keyErrs := me.keys()
+	errs = append(errs, keyErrs...)
+	if len(keyErrs) == 0 && signer == nil && middlewareType != Session &&
+		renewal != RenewNever {
+		fail("renewal", fmt.Errorf(
+			"%w: the tokens can't be renewed without private_key; "+
+				"set renewal to \"never\"", ErrConflict,
+		))
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	conf, err := New(
+		me.SessionName, middlewareType, signer,
+		me.Audience, me.Issuer, me.Subject, expireIn,
+		CookieConfig{
+			Path:     cmp.Or(me.Cookie.Path, "/"),
+			Domain:   me.Cookie.Domain,
+			Secure:   me.Cookie.Secure == nil || *me.Cookie.Secure,
+			HTTPOnly: me.Cookie.HTTPOnly == nil || *me.Cookie.HTTPOnly,
+			SameSite: sameSite,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	conf.Verifier = verifier
+	conf.RenewalPolicy = renewal
+	conf.RenewThreshold = renewThreshold
+	conf.MaxSessionAge = maxSessionAge
+	conf.RefreshName = me.RefreshName
+	conf.RefreshExpireIn = refreshExpireIn
+	conf.Leeway = leeway
+	conf.Tenant = me.Tenant
+	if err := conf.validate(false); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+func keysOf[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// keys loads the key material of the algorithm.
+func (me *Settings) keys() (jwt.Signer, jwt.Verifier, []error) {
+	var errs []error
+	fail := func(field string, err error) {
+		errs = append(errs, &FieldError{Field: field, Err: err})
+	}
+	conflict := func(field, algorithm string) {
+		fail(field, fmt.Errorf("%w: %s can't be used with %s", ErrConflict,
+			field, algorithm))
+	}
+
+	switch me.Algorithm {
+	case "":
+		fail("algorithm", ErrRequired)
+		return nil, nil, errs
+	case "HS256", "HS384", "HS512":
+		for _, setting := range []struct{ field, value string }{
+			{"private_key", me.PrivateKey},
+			{"private_key_file", me.PrivateKeyFile},
+			{"public_key", me.PublicKey},
+			{"public_key_file", me.PublicKeyFile},
+		} {
+			if setting.value != "" {
+				conflict(setting.field, me.Algorithm)
+			}
+		}
+		if me.Secret == "" {
+			fail("secret", ErrRequired)
+			return nil, nil, errs
+		}
+		secret, err := base64.StdEncoding.DecodeString(me.Secret)
+		if err != nil {
+			fail("secret", fmt.Errorf("invalid base64: %w", err))
+			return nil, nil, errs
+		}
+		signer, err := newHMAC(slog.Default(), me.Algorithm, secret)
+		if err != nil {
+			fail("secret", err)
+			return nil, nil, errs
+		}
+		return signer, nil, errs
+	case "EdDSA":
+		if me.Secret != "" {
+			conflict("secret", me.Algorithm)
+		}
+		private, err := readPEM(
+			"private_key", me.PrivateKey, me.PrivateKeyFile,
+		)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		public, err := readPEM("public_key", me.PublicKey, me.PublicKeyFile)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if len(errs) > 0 {
+			return nil, nil, errs
+		}
+		if private == nil && public == nil {
+			fail("private_key", fmt.Errorf(
+				"%w: EdDSA needs private_key or public_key", ErrRequired,
+			))
+			return nil, nil, errs
+		}
+		var signer jwt.Signer
+		var publicKey ed25519.PublicKey
+		if private != nil {
+			key, err := parseEd25519Private(private)
+			if err != nil {
+				fail("private_key", err)
+				return nil, nil, errs
+			}
+			signer = jwt.Ed25519Signer(key)
+			publicKey = key.Public().(ed25519.PublicKey)
+		}
+		if public != nil {
+			key, err := parseEd25519Public(public)
+			if err != nil {
+				fail("public_key", err)
+				return nil, nil, errs
+			}
+			if publicKey != nil && !publicKey.Equal(key) {
+				fail("public_key", fmt.Errorf(
+					"%w: public_key doesn't match private_key", ErrConflict,
+				))
+				return nil, nil, errs
+			}
+			publicKey = key
+		}
+		return signer, jwt.Ed25519Verifier(publicKey), errs
+	default:
+		fail("algorithm", fmt.Errorf(
+			"unsupported algorithm %q, must be one of "+
+				"HS256, HS384, HS512, EdDSA", me.Algorithm,
+		))
+		return nil, nil, errs
+	}
+}
+
+// readPEM returns the PEM block of the inline value or the file. It returns
+// nil if neither is set.
+func readPEM(field, value, path string) (*pem.Block, error) {
+	if value != "" && path != "" {
+		return nil, &FieldError{Field: field, Err: fmt.Errorf(
+			"%w: %s and %s_file are both set", ErrConflict, field, field,
+		)}
+	}
+	data := []byte(value)
+	if path != "" {
+		field += "_file"
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, &FieldError{Field: field, Err: err}
+		}
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, &FieldError{
+			Field: field, Err: errors.New("no PEM block is found"),
+		}
+	}
+	return block, nil
+}
+
+func parseEd25519Private(block *pem.Block) (ed25519.PrivateKey, error) {
+	if block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("unexpected PEM block %q", block.Type)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	private, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%T is not an Ed25519 key", key)
+	}
+	return private, nil
+}
+
+func parseEd25519Public(block *pem.Block) (ed25519.PublicKey, error) {
+	if block.Type != "PUBLIC KEY" {
+		return nil, fmt.Errorf("unexpected PEM block %q", block.Type)
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	public, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%T is not an Ed25519 key", key)
+	}
+	return public, nil
+}
@@ -0,0 +1,235 @@
+package config_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"codeberg.org/gbrlsnchs/jwt"
+	"gotest.tools/v3/assert"
+
+	_conf "github.com/hiroaki-yamamoto/gauth/config"
+)
+
+// Config loader test
+
+const (
+	secret    = "MDEyMzQ1Njc4OTAxMjM0NTY3ODkwMTIzNDU2Nzg5MDE="
+	secret512 = "MDEyMzQ1Njc4OTAxMjM0NTY3ODkwMTIzNDU2Nzg5MDEyMzQ1Njc4OTAx" +
+		"MjM0NTY3ODkwMTIzNDU2Nzg5MDEyMw=="
+)
+
+func TestLoad(t *testing.T) {
+	json := `{
+		"session_name": "session", "middleware": "bearer",
+		"algorithm": "HS256", "secret": "` + secret + `",
+		"issuer": "Test Issuer", "expire_in": "15m",
+		"renewal": "before_expiry", "renew_threshold": "5m",
+		"cookie": {"secure": true, "same_site": "strict"}
+	}`
+	yaml := `
+session_name: session
+middleware: bearer
+algorithm: HS256
+secret: ` + secret + `
+issuer: Test Issuer
+expire_in: 15m
+renewal: before_expiry
+renew_threshold: 5m
+cookie: {secure: true, same_site: strict}
+`
+	toml := `
+session_name = "session"
+middleware = "bearer"
+algorithm = "HS256"
+secret = "` + secret + `"
+issuer = "Test Issuer"
+expire_in = "15m"
+renewal = "before_expiry"
+renew_threshold = "5m"
+
+[cookie]
+secure = true
+same_site = "strict"
+`
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"json": json, "yaml": yaml, "toml": toml,
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, "gauth."+name)
+			assert.NilError(t, os.WriteFile(path, []byte(data), 0o600))
+			conf, err := _conf.LoadFile(path)
+			assert.NilError(t, err)
+			assert.Equal(t, conf.SessionName, "session")
+			assert.Equal(t, conf.MiddlewareType, _conf.Bearer)
+			assert.Equal(t, conf.Signer.Name(), "HS256")
+			assert.Equal(t, conf.Issuer, "Test Issuer")
+			assert.Equal(t, conf.ExpireIn, 15*time.Minute)
+			assert.Equal(t, conf.RenewalPolicy, _conf.RenewBeforeExpiry)
+			assert.Equal(t, conf.RenewThreshold, 5*time.Minute)
+			assert.Assert(t, conf.Secure)
+			assert.Assert(t, conf.HTTPOnly)
+			assert.Equal(t, conf.Path, "/")
+			assert.Equal(t, conf.SameSite, http.SameSiteStrictMode)
+		})
+	}
+	t.Run("Insecure cookie", func(t *testing.T) {
+		conf, err := _conf.LoadJSON([]byte(`{
+			"session_name": "session", "algorithm": "HS256",
+			"secret": "` + secret + `", "cookie": {
+				"path": "/app", "secure": false, "http_only": false
+			}
+		}`))
+		assert.NilError(t, err)
+		assert.Assert(t, !conf.Secure)
+		assert.Assert(t, !conf.HTTPOnly)
+		assert.Equal(t, conf.Path, "/app")
+	})
+	t.Run("Unknown field", func(t *testing.T) {
+		_, err := _conf.LoadYAML([]byte("session_nmae: session\n"))
+		assert.ErrorContains(t, err, "session_nmae")
+	})
+	t.Run("Unknown extension", func(t *testing.T) {
+		path := filepath.Join(dir, "gauth.ini")
+		assert.NilError(t, os.WriteFile(path, nil, 0o600))
+		_, err := _conf.LoadFile(path)
+		assert.Error(t, err, `config: unknown file extension ".ini"`)
+	})
+}
+
+func TestLoadEnv(t *testing.T) {
+	t.Setenv("GAUTH_SESSION_NAME", "session")
+	t.Setenv("GAUTH_ALGORITHM", "HS512")
+	t.Setenv("GAUTH_SECRET", secret512)
+	t.Setenv("GAUTH_LEEWAY", "30s")
+	t.Setenv("GAUTH_COOKIE_HTTP_ONLY", "true")
+	conf, err := _conf.LoadEnv("GAUTH")
+	assert.NilError(t, err)
+	assert.Equal(t, conf.SessionName, "session")
+	assert.Equal(t, conf.MiddlewareType, _conf.Cookie)
+	assert.Equal(t, conf.Signer.Name(), "HS512")
+	assert.Equal(t, conf.Leeway, 30*time.Second)
+	assert.Assert(t, conf.Secure)
+	assert.Assert(t, conf.HTTPOnly)
+	assert.Equal(t, conf.Path, "/")
+
+	t.Setenv("GAUTH_COOKIE_SECURE", "false")
+	conf, err = _conf.LoadEnv("GAUTH")
+	assert.NilError(t, err)
+	assert.Assert(t, !conf.Secure)
+
+	t.Setenv("GAUTH_COOKIE_SECURE", "maybe")
+	_, err = _conf.LoadEnv("GAUTH")
+	var fieldErr *_conf.FieldError
+	assert.Assert(t, errors.As(err, &fieldErr))
+	assert.Equal(t, fieldErr.Field, "cookie.secure")
+}
+
+func TestLoadEdDSA(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	assert.NilError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	assert.NilError(t, err)
+	privatePEM := pem.EncodeToMemory(
+		&pem.Block{Type: "PRIVATE KEY", Bytes: der},
+	)
+	der, err = x509.MarshalPKIXPublicKey(public)
+	assert.NilError(t, err)
+	publicPEM := pem.EncodeToMemory(
+		&pem.Block{Type: "PUBLIC KEY", Bytes: der},
+	)
+	path := filepath.Join(t.TempDir(), "private.pem")
+	assert.NilError(t, os.WriteFile(path, privatePEM, 0o600))
+
+	t.Run("Private key file", func(t *testing.T) {
+		settings := _conf.Settings{
+			SessionName: "session", Algorithm: "EdDSA", PrivateKeyFile: path,
+		}
+		conf, err := settings.Build()
+		assert.NilError(t, err)
+		assert.Equal(t, conf.Signer.Name(), "EdDSA")
+		sig, err := conf.Signer.Sign([]byte("payload"))
+		assert.NilError(t, err)
+		assert.NilError(t, conf.Verifier.Verify([]byte("payload"), sig))
+	})
+	t.Run("Verification only", func(t *testing.T) {
+		settings := _conf.Settings{
+			SessionName: "session", Algorithm: "EdDSA",
+			PublicKey: string(publicPEM),
+		}
+		_, err := settings.Build()
+		assert.Error(t, err, `config renewal: conflicting settings: `+
+			`the tokens can't be renewed without private_key; `+
+			`set renewal to "never"`)
+
+		settings.Renewal = "never"
+		conf, err := settings.Build()
+		assert.NilError(t, err)
+		assert.NilError(t, conf.Validate())
+		assert.Assert(t, conf.Signer == nil)
+		assert.DeepEqual(t, conf.Verifier, jwt.Ed25519Verifier(public))
+	})
+	t.Run("Mismatched keys", func(t *testing.T) {
+		other, _, err := ed25519.GenerateKey(rand.Reader)
+		assert.NilError(t, err)
+		der, err := x509.MarshalPKIXPublicKey(other)
+		assert.NilError(t, err)
+		settings := _conf.Settings{
+			SessionName: "session", Algorithm: "EdDSA", PrivateKeyFile: path,
+			PublicKey: string(
+				pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}),
+			),
+		}
+		_, err = settings.Build()
+		assert.ErrorIs(t, err, _conf.ErrConflict)
+	})
+}
+
+func TestBuildErrors(t *testing.T) {
+	settings := _conf.Settings{
+		Middleware: "websocket", Algorithm: "EdDSA", Secret: secret,
+		ExpireIn: "-1h", Renewal: "before_expiry",
+		Cookie: _conf.CookieSettings{SameSite: "lax"},
+	}
+	_, err := settings.Build()
+	assert.ErrorIs(t, err, _conf.ErrRequired)
+	assert.ErrorIs(t, err, _conf.ErrConflict)
+	assert.Error(t, err, `config session_name: required setting is missing
+config middleware: unknown value "websocket", must be one of bearer, `+
+		`cookie, header, session
+config expire_in: negative duration -1h
+config renew_threshold: required setting is missing: `+
+		`renewal "before_expiry" needs the threshold
+config secret: conflicting settings: secret can't be used with EdDSA`)
+
+	settings = _conf.Settings{
+		SessionName: "session", Algorithm: "HS256", Secret: "c2hvcnQ=",
+	}
+	_, err = settings.Build()
+	assert.ErrorIs(t, err, jwt.ErrHMACKeyTooShort)
+
+	settings = _conf.Settings{
+		SessionName: "session", Algorithm: "HS256", Secret: secret,
+		Cookie: _conf.CookieSettings{SameSite: "none", Secure: new(bool)},
+	}
+	_, err = settings.Build()
+	assert.ErrorIs(t, err, _conf.ErrConflict)
+	assert.Error(t, err, "config CookieConfig.Secure: conflicting settings: "+
+		"SameSite=None requires Secure")
+
+	// The stores are set after Build.
+	settings = _conf.Settings{
+		SessionName: "session", Middleware: "session", Algorithm: "HS256",
+		Secret: secret,
+	}
+	_, err = settings.Build()
+	assert.NilError(t, err)
+}
@@ -0,0 +1,212 @@
+package config
+
+// Functional options of Config
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"codeberg.org/gbrlsnchs/jwt"
+	"github.com/hiroaki-yamamoto/gauth/audit"
+	"github.com/hiroaki-yamamoto/gauth/store"
+)
+
+// DefaultExpireIn is the lifetime of the tokens that NewWith sets by default.
+const DefaultExpireIn = time.Hour
+
+// Option configures Config in NewWith. Any function of this type can be
+// an option, e.g. to set the fields that have no option below.
+type Option func(c *Config) error
+
+// NewWith creates a new Config with the secure defaults, applies opts, and
+// validates it with Validate. The defaults are:
+//
+//   - Cookie middleware with the cookie of Path "/", Secure, HttpOnly and
+//     SameSite=Lax.
+//   - DefaultExpireIn as the lifetime of the tokens.
+//
+// signer can be nil when WithVerifier or WithHMAC is given.
+func NewWith(
+	sessionName string,
+	signer jwt.Signer,
+	opts ...Option,
+) (*Config, error) {
+	c := &Config{
+		CookieConfig: CookieConfig{
+			Path: "/", Secure: true, HTTPOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		},
+		SessionName: sessionName,
+		Signer:      signer,
+		ExpireIn:    DefaultExpireIn,
+	}
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// WithMiddlewareType sets Config.MiddlewareType.
+func WithMiddlewareType(middlewareType MiddlewareType) Option {
+	return func(c *Config) error {
+		c.MiddlewareType = middlewareType
+		return nil
+	}
+}
+
+// WithHMAC sets the HMAC signer of algorithm ("HS256", "HS384" or "HS512")
+// with key. The key shorter than the hash size of the algorithm is
+// rejected, and the key that looks like a passphrase is warned through
+// Config.Log(). Place this after WithLogger to warn through the logger.
+func WithHMAC(algorithm string, key []byte) Option {
+	return func(c *Config) error {
+		signer, err := newHMAC(c.Log(), algorithm, key)
+		if err != nil {
+			return &FieldError{Field: "Signer", Err: err}
+		}
+		c.Signer = signer
+		return nil
+	}
+}
+
+// WithVerifier sets Config.Verifier.
+func WithVerifier(verifier jwt.Verifier) Option {
+	return func(c *Config) error {
+		c.Verifier = verifier
+		return nil
+	}
+}
+
+// WithClaims sets the audience, the issuer and the subject of the tokens.
+func WithClaims(audience, issuer, subject string) Option {
+	return func(c *Config) error {
+		c.Audience, c.Issuer, c.Subject = audience, issuer, subject
+		return nil
+	}
+}
+
+// WithExpireIn sets Config.ExpireIn.
+func WithExpireIn(expireIn time.Duration) Option {
+	return func(c *Config) error {
+		c.ExpireIn = expireIn
+		return nil
+	}
+}
+
+// WithCookie replaces the secure default of the cookie with cookieConf.
+func WithCookie(cookieConf CookieConfig) Option {
+	return func(c *Config) error {
+		c.CookieConfig = cookieConf
+		return nil
+	}
+}
+
+// WithInsecureCookie unsets Secure of the cookie, so that the cookie is sent
+// over plain HTTP, e.g. in the local development.
+func WithInsecureCookie() Option {
+	return func(c *Config) error {
+		c.Secure = false
+		return nil
+	}
+}
+
+// WithHostPrefix prepends HostPrefix to the session name, so that the
+// cookie is bound to the host. Validate rejects the cookie settings that
+// break the rules of the prefix, such as Domain.
+func WithHostPrefix() Option {
+	return func(c *Config) error {
+		c.SessionName = HostPrefix + c.SessionName
+		return nil
+	}
+}
+
+// WithRenewal sets Config.RenewalPolicy and Config.RenewThreshold.
+func WithRenewal(policy RenewalPolicy, threshold time.Duration) Option {
+	return func(c *Config) error {
+		c.RenewalPolicy, c.RenewThreshold = policy, threshold
+		return nil
+	}
+}
+
+// WithRefresh sets Config.RefreshStore and Config.RefreshExpireIn.
+func WithRefresh(
+	refreshStore store.RefreshStore,
+	expireIn time.Duration,
+) Option {
+	return func(c *Config) error {
+		c.RefreshStore, c.RefreshExpireIn = refreshStore, expireIn
+		return nil
+	}
+}
+
+// WithRevoker sets Config.Revoker.
+func WithRevoker(revoker store.Revoker) Option {
+	return func(c *Config) error {
+		c.Revoker = revoker
+		return nil
+	}
+}
+
+// WithSessionStore sets Config.SessionStore.
+func WithSessionStore(sessionStore store.SessionStore) Option {
+	return func(c *Config) error {
+		c.SessionStore = sessionStore
+		return nil
+	}
+}
+
+// WithLogger sets Config.Logger.
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *Config) error {
+		c.Logger = logger
+		return nil
+	}
+}
+
+// WithHook sets Config.Hook.
+func WithHook(hook audit.Hook) Option {
+	return func(c *Config) error {
+		c.Hook = hook
+		return nil
+	}
+}
+
+// WithTenant sets Config.Tenant.
+func WithTenant(tenant string) Option {
+	return func(c *Config) error {
+		c.Tenant = tenant
+		return nil
+	}
+}
+
+// newHMAC creates the HMAC signer of algorithm, and warns the weak key.
+func newHMAC(
+	log *slog.Logger,
+	algorithm string,
+	key []byte,
+) (*jwt.HMACSHA, error) {
+	var signer *jwt.HMACSHA
+	var err error
+	switch algorithm {
+	case "HS256":
+		signer, err = jwt.NewHS256(key)
+	case "HS384":
+		signer, err = jwt.NewHS384(key)
+	case "HS512":
+		signer, err = jwt.NewHS512(key)
+	default:
+		return nil, fmt.Errorf("unsupported HMAC algorithm %q", algorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+	warnWeakHMACKey(log, algorithm, key)
+	return signer, nil
+}
@@ -0,0 +1,69 @@
+package config
+
+// Multi-tenant configuration
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ErrUnknownTenant is returned by the resolvers when the request doesn't
+// belong to any tenant.
+var ErrUnknownTenant = errors.New("unknown tenant")
+
+// ConfigResolver selects the Config of the tenant that the request belongs
+// to, so that a middleware instance can serve multiple tenants.
+type ConfigResolver interface {
+	Resolve(r *http.Request) (*Config, error)
+}
+
+// ConfigResolverFunc is an adapter to use a function as ConfigResolver.
+type ConfigResolverFunc func(r *http.Request) (*Config, error)
+
+// Resolve implements ConfigResolver.
+func (me ConfigResolverFunc) Resolve(r *http.Request) (*Config, error) {
+	return me(r)
+}
+
+// HostResolver resolves the Config by Host header of the request. The keys
+// are the host names without the port, in lower case.
+type HostResolver map[string]*Config
+
+// Resolve implements ConfigResolver.
+func (me HostResolver) Resolve(r *http.Request) (*Config, error) {
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host = r.Host
+	}
+	if conf, ok := me[strings.ToLower(host)]; ok {
+		return conf, nil
+	}
+	return nil, ErrUnknownTenant
+}
+
+// PathResolver resolves the Config by the path prefix of the request, e.g.
+// "/acme" for "/acme/orders". The longest prefix is used when multiple
+// prefixes match. A prefix matches at the boundary of the path segments,
+// i.e. "/acme" doesn't match "/acmecorp".
+type PathResolver map[string]*Config
+
+// Resolve implements ConfigResolver.
+func (me PathResolver) Resolve(r *http.Request) (*Config, error) {
+	var found *Config
+	longest := -1
+	for prefix, conf := range me {
+		base := strings.TrimSuffix(prefix, "/")
+		if r.URL.Path != base && !strings.HasPrefix(r.URL.Path, base+"/") {
+			continue
+		}
+		if len(base) > longest {
+			found, longest = conf, len(base)
+		}
+	}
+	if found == nil {
+		return nil, ErrUnknownTenant
+	}
+	return found, nil
+}
@@ -0,0 +1,50 @@
+package config_test
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	_conf "github.com/hiroaki-yamamoto/gauth/config"
+	"gotest.tools/v3/assert"
+)
+
+// Config resolver test
+
+func TestHostResolver(t *testing.T) {
+	acme, initech := &_conf.Config{Tenant: "acme"}, &_conf.Config{}
+	resolver := _conf.HostResolver{
+		"acme.example.com": acme, "initech.example.com": initech,
+	}
+	req := httptest.NewRequest("GET", "/", nil)
+	for host, expected := range map[string]*_conf.Config{
+		"acme.example.com":         acme,
+		"ACME.example.com:8443":    acme,
+		"initech.example.com:8080": initech,
+	} {
+		req.Host = host
+		conf, err := resolver.Resolve(req)
+		assert.NilError(t, err)
+		assert.Equal(t, conf, expected, host)
+	}
+	req.Host = "evil.example.com"
+	_, err := resolver.Resolve(req)
+	assert.ErrorIs(t, err, _conf.ErrUnknownTenant)
+}
+
+func TestPathResolver(t *testing.T) {
+	acme, orders := &_conf.Config{Tenant: "acme"}, &_conf.Config{}
+	resolver := _conf.PathResolver{"/acme": acme, "/acme/orders/": orders}
+	for path, expected := range map[string]*_conf.Config{
+		"/acme":              acme,
+		"/acme/users":        acme,
+		"/acme/orders":       orders,
+		"/acme/orders/1":     orders,
+		"/acme/orders-2/foo": acme,
+	} {
+		conf, err := resolver.Resolve(httptest.NewRequest("GET", path, nil))
+		assert.NilError(t, err)
+		assert.Equal(t, conf, expected, path)
+	}
+	_, err := resolver.Resolve(httptest.NewRequest("GET", "/acmecorp", nil))
+	assert.ErrorIs(t, err, _conf.ErrUnknownTenant)
+}
@@ -0,0 +1,147 @@
+package config
+
+// Sources of the settings
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// LoadJSON loads Config from the settings in JSON. The unknown fields are
+// rejected so that the typos don't go unnoticed.
+func LoadJSON(data []byte) (*Config, error) {
+	var settings Settings
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&settings); err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+	return settings.Build()
+}
+
+// LoadYAML loads Config from the settings in YAML. The unknown fields are
+// rejected as well as LoadJSON.
+func LoadYAML(data []byte) (*Config, error) {
+	var settings Settings
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&settings); err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+	return settings.Build()
+}
+
+// LoadTOML loads Config from the settings in TOML. The unknown fields are
+// rejected as well as LoadJSON.
+func LoadTOML(data []byte) (*Config, error) {
+	var settings Settings
+	decoder := toml.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&settings); err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+	return settings.Build()
+}
+
+// LoadFile loads Config from the file. The format is chosen by the
+// extension: ".json", ".yaml", ".yml" or ".toml". The relative paths of
+// the key files are resolved from the working directory, not from the file.
+func LoadFile(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		return LoadJSON(data)
+	case ".yaml", ".yml":
+		return LoadYAML(data)
+	case ".toml":
+		return LoadTOML(data)
+	default:
+		return nil, fmt.Errorf("config: unknown file extension %q", ext)
+	}
+}
+
+// LoadEnv loads Config from the environment variables. The name of each
+// variable is prefix and the upper-cased name of the setting joined by "_",
+// e.g. GAUTH_SESSION_NAME, GAUTH_EXPIRE_IN and GAUTH_COOKIE_SAME_SITE for
+// the prefix "GAUTH". The boolean settings accept the values of
+// strconv.ParseBool.
+func LoadEnv(prefix string) (*Config, error) {
+	var settings Settings
+	if err := settings.LookupEnv(prefix, os.LookupEnv); err != nil {
+		return nil, err
+	}
+	return settings.Build()
+}
+
+// LookupEnv overrides the settings by the variables that lookup finds.
+// See LoadEnv for the names of the variables. This can be used to override
+// the settings that are loaded from a file:
+//
+//	var settings config.Settings
+//	// Decode the file into settings.
+//	err := settings.LookupEnv("GAUTH", os.LookupEnv)
+func (me *Settings) LookupEnv(
+	prefix string,
+	lookup func(name string) (string, bool),
+) error {
+	return lookupEnv(reflect.ValueOf(me).Elem(), prefix, "", lookup)
+}
+
+func lookupEnv(
+	value reflect.Value,
+	prefix, field string,
+	lookup func(name string) (string, bool),
+) error {
+	for i := range value.NumField() {
+		tag, _, _ := strings.Cut(value.Type().Field(i).Tag.Get("json"), ",")
+		name := tag
+		if field != "" {
+			name = field + "." + tag
+		}
+		env := strings.ToUpper(strings.ReplaceAll(name, ".", "_"))
+		if prefix != "" {
+			env = prefix + "_" + env
+		}
+		target := value.Field(i)
+		if target.Kind() == reflect.Struct {
+			if err := lookupEnv(target, prefix, name, lookup); err != nil {
+				return err
+			}
+			continue
+		}
+		raw, ok := lookup(env)
+		if !ok {
+			continue
+		}
+		if target.Kind() == reflect.Pointer {
+			pointer := reflect.New(target.Type().Elem())
+			target.Set(pointer)
+			target = pointer.Elem()
+		}
+		switch target.Kind() {
+		case reflect.Bool:
+			flag, err := strconv.ParseBool(raw)
+			if err != nil {
+				return &FieldError{
+					Field: name, Err: fmt.Errorf("%s: %w", env, err),
+				}
+			}
+			target.SetBool(flag)
+		default:
+			target.SetString(raw)
+		}
+	}
+	return nil
+}
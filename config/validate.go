@@ -0,0 +1,167 @@
+package config
+
+// Validation of Config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+
+	"codeberg.org/gbrlsnchs/jwt"
+)
+
+const (
+	// HostPrefix is the cookie name prefix that requires Secure, Path "/"
+	// and no Domain, so that the cookie is bound to the host.
+	HostPrefix = "__Host-"
+	// SecurePrefix is the cookie name prefix that requires Secure.
+	SecurePrefix = "__Secure-"
+)
+
+// Validate checks the invariants of Config so that the misconfiguration is
+// found on start-up instead of the requests. All the violations are
+// reported at once as FieldError joined by errors.Join. The checks are:
+//
+//   - SessionName is set.
+//   - Signer or Verifier is set, and Signer can verify the tokens if
+//     Verifier is nil. Without Signer, RenewalPolicy is RenewNever
+//     unless the middleware is Session.
+//   - ExpireIn is positive, and the other durations aren't negative.
+//   - RenewThreshold is set for RenewBeforeExpiry.
+//   - SessionStore is set for Session.
+//   - The cookie with SameSite=None is Secure, and the cookie names with
+//     HostPrefix and SecurePrefix meet their rules, when the middleware
+//     uses the cookie.
+func (c *Config) Validate() error {
+	return c.validate(true)
+}
+
+// validate checks the invariants of Config. The stores are checked only if
+// stores is true.
+func (c *Config) validate(stores bool) error {
+	var errs []error
+	fail := func(field string, err error) {
+		errs = append(errs, &FieldError{Field: field, Err: err})
+	}
+
+	if c.SessionName == "" {
+		fail("SessionName", ErrRequired)
+	}
+	switch c.MiddlewareType {
+	case Cookie, Header, Bearer, Session:
+	default:
+		fail("MiddlewareType", fmt.Errorf(
+			"unknown middleware type %d", c.MiddlewareType,
+		))
+	}
+	if c.Signer == nil && c.Verifier == nil {
+		fail("Signer", ErrRequired)
+	} else if c.Signer == nil && c.MiddlewareType != Session &&
+		c.RenewalPolicy != RenewNever {
+		fail("RenewalPolicy", fmt.Errorf(
+			"%w: the tokens can't be renewed without Signer; use RenewNever",
+			ErrConflict,
+		))
+	} else if c.Verifier == nil {
+		if _, ok := c.Signer.(jwt.Verifier); !ok {
+			fail("Verifier", fmt.Errorf(
+				"%w: %T can't verify the tokens", ErrRequired, c.Signer,
+			))
+		}
+	}
+	if c.ExpireIn <= 0 {
+		fail("ExpireIn", fmt.Errorf("must be positive, got %s", c.ExpireIn))
+	}
+	for _, duration := range []struct {
+		field string
+		value time.Duration
+	}{
+		{"RenewThreshold", c.RenewThreshold},
+		{"MaxSessionAge", c.MaxSessionAge},
+		{"RefreshExpireIn", c.RefreshExpireIn},
+		{"Leeway", c.Leeway},
+	} {
+		if duration.value < 0 {
+			fail(duration.field, fmt.Errorf(
+				"negative duration %s", duration.value,
+			))
+		}
+	}
+	if c.RenewalPolicy == RenewBeforeExpiry && c.RenewThreshold <= 0 {
+		fail("RenewThreshold", fmt.Errorf(
+			"%w: RenewBeforeExpiry needs the threshold", ErrRequired,
+		))
+	}
+	if stores && c.MiddlewareType == Session && c.SessionStore == nil {
+		fail("SessionStore", fmt.Errorf(
+			"%w: Session middleware needs the store", ErrRequired,
+		))
+	}
+	if c.MiddlewareType.UsesCookie() {
+		errs = append(errs, c.validateCookie()...)
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Config) validateCookie() []error {
+	var errs []error
+	fail := func(field string, err error) {
+		errs = append(errs, &FieldError{
+			Field: "CookieConfig." + field, Err: err,
+		})
+	}
+	names := []string{c.SessionName, c.RefreshSessionName()}
+	hasPrefix := func(prefix string) bool {
+		return strings.HasPrefix(names[0], prefix) ||
+			strings.HasPrefix(names[1], prefix)
+	}
+
+	if c.SameSite == http.SameSiteNoneMode && !c.Secure {
+		fail("Secure", fmt.Errorf(
+			"%w: SameSite=None requires Secure", ErrConflict,
+		))
+	}
+	switch {
+	case hasPrefix(HostPrefix):
+		if !c.Secure {
+			fail("Secure", fmt.Errorf(
+				"%w: %s cookie requires Secure", ErrConflict, HostPrefix,
+			))
+		}
+		if c.Path != "/" {
+			fail("Path", fmt.Errorf(
+				"%w: %s cookie requires Path \"/\"", ErrConflict, HostPrefix,
+			))
+		}
+		if c.Domain != "" {
+			fail("Domain", fmt.Errorf(
+				"%w: %s cookie can't have Domain", ErrConflict, HostPrefix,
+			))
+		}
+	case hasPrefix(SecurePrefix) && !c.Secure:
+		fail("Secure", fmt.Errorf(
+			"%w: %s cookie requires Secure", ErrConflict, SecurePrefix,
+		))
+	}
+	return errs
+}
+
+// warnWeakHMACKey warns if key looks like a passphrase. Such a key has far
+// less entropy than the random bytes of the same length, even if it meets
+// the minimum length of the algorithm.
+func warnWeakHMACKey(log *slog.Logger, algorithm string, key []byte) {
+	for _, b := range key {
+		if b > unicode.MaxASCII || !unicode.IsPrint(rune(b)) {
+			return
+		}
+	}
+	log.Warn(
+		"weak HMAC key: the key looks like a passphrase; "+
+			"use random bytes instead",
+		slog.String("algorithm", algorithm), slog.Int("length", len(key)),
+	)
+}
@@ -0,0 +1,156 @@
+package config_test
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+
+	"codeberg.org/gbrlsnchs/jwt"
+	"gotest.tools/v3/assert"
+
+	_conf "github.com/hiroaki-yamamoto/gauth/config"
+	"github.com/hiroaki-yamamoto/gauth/store"
+)
+
+// Config validation test
+
+type signOnly struct{}
+
+func (signOnly) Name() string                { return "none" }
+func (signOnly) Sign([]byte) ([]byte, error) { return nil, nil }
+func (signOnly) Size() int                   { return 0 }
+
+func TestValidate(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		conf, err := _conf.New(
+			"session", _conf.Header, mustHS256("test"),
+			"", "", "", 0, _conf.CookieConfig{},
+		)
+		assert.NilError(t, err)
+		assert.NilError(t, conf.Validate())
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		conf := &_conf.Config{
+			MiddlewareType: _conf.Session, Signer: signOnly{},
+			Leeway: -time.Second, RenewalPolicy: _conf.RenewBeforeExpiry,
+			CookieConfig: _conf.CookieConfig{SameSite: http.SameSiteNoneMode},
+		}
+		err := conf.Validate()
+		assert.ErrorIs(t, err, _conf.ErrRequired)
+		assert.ErrorIs(t, err, _conf.ErrConflict)
+		assert.Error(t, err, `config SessionName: required setting is missing
+config Verifier: required setting is missing: `+
+			`config_test.signOnly can't verify the tokens
+config ExpireIn: must be positive, got 0s
+config Leeway: negative duration -1s
+config RenewThreshold: required setting is missing: `+
+			`RenewBeforeExpiry needs the threshold
+config SessionStore: required setting is missing: `+
+			`Session middleware needs the store
+config CookieConfig.Secure: conflicting settings: `+
+			`SameSite=None requires Secure`)
+	})
+	t.Run("Verification only", func(t *testing.T) {
+		conf, err := _conf.New(
+			"session", _conf.Header, nil,
+			"", "", "", 0, _conf.CookieConfig{},
+		)
+		assert.NilError(t, err)
+		conf.Verifier = mustHS256("test").(jwt.Verifier)
+		assert.Error(t, conf.Validate(), `config RenewalPolicy: `+
+			`conflicting settings: the tokens can't be renewed `+
+			`without Signer; use RenewNever`)
+
+		conf.RenewalPolicy = _conf.RenewNever
+		assert.NilError(t, conf.Validate())
+	})
+	t.Run("Host prefix", func(t *testing.T) {
+		conf, err := _conf.New(
+			"__Host-session", _conf.Cookie, mustHS256("test"),
+			"", "", "", 0, _conf.CookieConfig{Domain: "example.com"},
+		)
+		assert.NilError(t, err)
+		assert.Error(t, conf.Validate(), `config CookieConfig.Secure: `+
+			`conflicting settings: __Host- cookie requires Secure
+config CookieConfig.Path: `+
+			`conflicting settings: __Host- cookie requires Path "/"
+config CookieConfig.Domain: `+
+			`conflicting settings: __Host- cookie can't have Domain`)
+
+		conf.MiddlewareType = _conf.Header
+		assert.NilError(t, conf.Validate())
+	})
+	t.Run("Secure prefix", func(t *testing.T) {
+		conf, err := _conf.New(
+			"__Secure-session", _conf.Cookie, mustHS256("test"),
+			"", "", "", 0, _conf.CookieConfig{},
+		)
+		assert.NilError(t, err)
+		var fieldErr *_conf.FieldError
+		assert.Assert(t, errors.As(conf.Validate(), &fieldErr))
+		assert.Equal(t, fieldErr.Field, "CookieConfig.Secure")
+	})
+}
+
+func TestNewWith(t *testing.T) {
+	t.Run("Defaults", func(t *testing.T) {
+		conf, err := _conf.NewWith(
+			"session", mustHS256("test"), _conf.WithHostPrefix(),
+		)
+		assert.NilError(t, err)
+		assert.Equal(t, conf.SessionName, "__Host-session")
+		assert.Equal(t, conf.MiddlewareType, _conf.Cookie)
+		assert.Equal(t, conf.ExpireIn, _conf.DefaultExpireIn)
+		assert.DeepEqual(t, conf.CookieConfig, _conf.CookieConfig{
+			Path: "/", Secure: true, HTTPOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+	})
+	t.Run("Options", func(t *testing.T) {
+		sessions := store.NewMemorySessionStore()
+		conf, err := _conf.NewWith(
+			"session", nil,
+			_conf.WithHMAC("HS384", bytes.Repeat([]byte{0xa5}, 48)),
+			_conf.WithMiddlewareType(_conf.Session),
+			_conf.WithSessionStore(sessions),
+			_conf.WithClaims("Test Audience", "Test Issuer", "Test Subject"),
+			_conf.WithExpireIn(15*time.Minute),
+			_conf.WithInsecureCookie(),
+		)
+		assert.NilError(t, err)
+		assert.Equal(t, conf.Signer.Name(), "HS384")
+		assert.Equal(t, conf.SessionStore, store.SessionStore(sessions))
+		assert.Equal(t, conf.Issuer, "Test Issuer")
+		assert.Equal(t, conf.ExpireIn, 15*time.Minute)
+		assert.Assert(t, !conf.Secure)
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		conf, err := _conf.NewWith(
+			"__Host-session", mustHS256("test"),
+			_conf.WithCookie(_conf.CookieConfig{Path: "/", Secure: true}),
+			_conf.WithInsecureCookie(),
+		)
+		assert.ErrorIs(t, err, _conf.ErrConflict)
+		assert.Assert(t, conf == nil)
+	})
+	t.Run("Weak HMAC key", func(t *testing.T) {
+		var log bytes.Buffer
+		logger := slog.New(slog.NewTextHandler(&log, nil))
+		_, err := _conf.NewWith(
+			"session", nil, _conf.WithLogger(logger),
+			_conf.WithHMAC("HS256", []byte("correct horse battery staple!!!!")),
+		)
+		assert.NilError(t, err)
+		assert.Assert(t, bytes.Contains(log.Bytes(), []byte("weak HMAC key")))
+
+		_, err = _conf.NewWith(
+			"session", nil, _conf.WithHMAC("HS256", []byte("short")),
+		)
+		var fieldErr *_conf.FieldError
+		assert.Assert(t, errors.As(err, &fieldErr))
+		assert.Equal(t, fieldErr.Field, "Signer")
+	})
+}
@@ -0,0 +1,90 @@
+package core_test
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hiroaki-yamamoto/gauth/audit"
+	"github.com/hiroaki-yamamoto/gauth/clock"
+	"github.com/hiroaki-yamamoto/gauth/config"
+	"github.com/hiroaki-yamamoto/gauth/core"
+	"github.com/hiroaki-yamamoto/gauth/store"
+	"gotest.tools/v3/assert"
+)
+
+// Authentication event test
+
+type eventRecorder struct {
+	events []audit.Event
+}
+
+func (me *eventRecorder) Handle(event audit.Event) error {
+	me.events = append(me.events, event)
+	return nil
+}
+
+func (me *eventRecorder) types() []audit.EventType {
+	types := make([]audit.EventType, len(me.events))
+	for i, event := range me.events {
+		types[i] = event.Type
+	}
+	return types
+}
+
+func TestEvents(t *testing.T) {
+	now := time.Unix(time.Now().Unix(), 0).UTC()
+	fake := clock.NewFake(now)
+	hook := &eventRecorder{}
+	conf, err := config.New(
+		"session", config.Header, mustHS256("test"),
+		"Test Audience", "Test Issuer", "Test Subject",
+		time.Hour, config.CookieConfig{},
+	)
+	assert.NilError(t, err)
+	conf.RefreshStore = store.NewMemoryRefreshStore()
+	conf.Clock = fake
+	conf.Hook = hook
+	conf.Revoker = store.NewMemoryRevoker()
+	user := User{Username: "test_username"}
+
+	rec := httptest.NewRecorder()
+	assert.NilError(t, core.Login(rec, conf, user))
+	token := rec.Header().Get("X-" + conf.SessionName)
+	jot, err := core.ExtractAccess(token, conf)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, hook.events, []audit.Event{{
+		Type: audit.Login, Time: now,
+		UserID: "test_username", JWTID: jot.Claims.JWTID,
+	}})
+
+	fake.Advance(2 * conf.ExpireIn)
+	_, err = core.ExtractToken(token, conf)
+	assert.ErrorIs(t, err, core.ErrExpired)
+	assert.DeepEqual(t, hook.events[1], audit.Event{
+		Type: audit.VerificationFailed, Time: now.Add(2 * conf.ExpireIn),
+		JWTID: jot.Claims.JWTID, Reason: "jwt is expired",
+	})
+	fake.Set(now)
+
+	pair, err := core.ComposePair("test_username", conf)
+	assert.NilError(t, err)
+	refresh, err := core.ExtractRefresh(string(pair.Refresh), conf)
+	assert.NilError(t, err)
+	_, err = core.RotatePair(refresh, conf)
+	assert.NilError(t, err)
+	_, err = core.RotatePair(refresh, conf)
+	assert.ErrorIs(t, err, store.ErrReused)
+
+	req := httptest.NewRequest("POST", "/logout", nil)
+	req.Header.Set(conf.SessionName, token)
+	assert.NilError(t, core.Logout(httptest.NewRecorder(), req, conf))
+	logout := hook.events[len(hook.events)-1]
+	assert.Equal(t, logout.UserID, "test_username")
+	assert.Equal(t, logout.JWTID, jot.Claims.JWTID)
+
+	assert.DeepEqual(t, hook.types(), []audit.EventType{
+		audit.Login, audit.VerificationFailed, audit.Login, audit.Refresh,
+		audit.VerificationFailed, audit.Logout,
+	})
+}
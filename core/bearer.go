@@ -0,0 +1,18 @@
+package core
+
+import (
+	"net/http"
+	"strings"
+)
+
+// BearerToken returns the token in "Authorization: Bearer <token>" header of
+// the request (RFC 6750). The authentication scheme is case-insensitive.
+// An empty string is returned if the request doesn't have the header or the
+// scheme is not "Bearer".
+func BearerToken(r *http.Request) string {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
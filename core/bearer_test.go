@@ -0,0 +1,31 @@
+package core_test
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hiroaki-yamamoto/gauth/core"
+	"gotest.tools/v3/assert"
+)
+
+// Bearer token test
+
+func TestBearerToken(t *testing.T) {
+	for _, tc := range []struct {
+		name, header, expected string
+	}{
+		{"Bearer", "Bearer token", "token"},
+		{"Lower case", "bearer token", "token"},
+		{"Other scheme", "Basic token", ""},
+		{"No token", "Bearer", ""},
+		{"No header", "", ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			if tc.header != "" {
+				req.Header.Set("Authorization", tc.header)
+			}
+			assert.Equal(t, core.BearerToken(req), tc.expected)
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package core
+
+import (
+	"crypto/rand"
+	"time"
+
+	"codeberg.org/gbrlsnchs/jwt"
+	"github.com/hiroaki-yamamoto/gauth/config"
+	"github.com/hiroaki-yamamoto/gauth/models"
+)
+
+// Claims is the set of private claims that gauth embeds into the tokens
+// it issues, in addition to the registered ones.
+type Claims struct {
+	// UserID is the ID of the user that the token is issued for.
+	UserID string `json:"uid,omitzero"`
+	// Family is the ID of the refresh token family that the token belongs to.
+	Family string `json:"fam,omitzero"`
+	// AuthTime is the time the user logged in. The token is re-issued with
+	// this time kept so that the session can't be extended beyond
+	// Config.MaxSessionAge.
+	AuthTime jwt.NumericDate `json:"auth_time,omitzero"`
+	// SessionID is the ID of the session record on Config.SessionStore.
+	// The token is rejected once the session is revoked.
+	SessionID string `json:"sid,omitzero"`
+	// TokenVersion is the token version of the user that the token is issued
+	// for. See models.TokenVersioner.
+	TokenVersion int64 `json:"ver,omitzero"`
+	// Scope is the space-delimited scopes that the token is granted.
+	Scope string `json:"scope,omitzero"`
+	// Tenant is Config.Tenant of the config that issued the token.
+	Tenant string `json:"tid,omitzero"`
+}
+
+// CustomClaims is the set of private claims that consists of Claims and
+// the custom claims T that are passed to ComposeClaims.
+type CustomClaims[T any] struct {
+	Claims
+	// Custom is the custom claims. They are encoded at the same level as
+	// the other private claims. Note that "inline" option was renamed to
+	// "embed" in the released encoding/json/v2, so both are specified.
+	Custom T `json:",inline,embed"`
+}
+
+// newTokenID generates a random ID that is used as "jti" claim or as the ID
+// of a token family.
+func newTokenID() string {
+	return rand.Text()
+}
+
+// authTimeOf returns the time the user of the token logged in. For the tokens
+// without "auth_time" claim, "iat" claim is used instead.
+func authTimeOf(claims Claims, issuedAt jwt.NumericDate) time.Time {
+	if claims.AuthTime != 0 {
+		return claims.AuthTime.Time()
+	}
+	return issuedAt.Time()
+}
+
+// expireAt returns the expiration time of the token that is issued at now
+// with lifetime, capped by Config.MaxSessionAge since authTime.
+func expireAt(
+	now, authTime time.Time,
+	lifetime time.Duration,
+	conf *config.Config,
+) time.Time {
+	exp := now.Add(lifetime)
+	if conf.MaxSessionAge > 0 {
+		if limit := authTime.Add(conf.MaxSessionAge); exp.After(limit) {
+			return limit
+		}
+	}
+	return exp
+}
+
+// claimsOf returns the claims of the token that is issued for user.
+func claimsOf(user models.IUser) Claims {
+	return Claims{UserID: user.GetID(), TokenVersion: tokenVersionOf(user)}
+}
+
+// tokenVersionOf returns the token version of user. Zero is returned if
+// user doesn't implement models.TokenVersioner.
+func tokenVersionOf(user any) int64 {
+	if versioner, ok := user.(models.TokenVersioner); ok {
+		return versioner.GetTokenVersion()
+	}
+	return 0
+}
+
+// CheckTokenVersion returns ErrStaleToken as ValidationError if claims of
+// the token identified by jti was issued for an older token version of
+// user than the current one. user is the one that is looked up by "uid"
+// claim, and this function does nothing if it doesn't implement
+// models.TokenVersioner.
+func CheckTokenVersion(
+	conf *config.Config,
+	user any,
+	claims Claims,
+	jti string,
+) error {
+	version := tokenVersionOf(user)
+	if claims.TokenVersion >= version {
+		return nil
+	}
+	err := &ValidationError{
+		Claim: "ver", Expected: version, Actual: claims.TokenVersion,
+		JWTID: jti, Err: ErrStaleToken,
+	}
+	emitFailure(conf, claims.UserID, err)
+	return err
+}
+
+// privateClaimsOf returns the private claims of the verified token t that
+// extract checks. The token is decoded only if the config requires the
+// checks; otherwise, the zero value is returned.
+func privateClaimsOf(t *jwt.Token, conf *config.Config) (Claims, error) {
+	if conf.SessionStore == nil && conf.Tenant == "" {
+		return Claims{}, nil
+	}
+	jot, err := jwt.Decode[Claims](t)
+	if err != nil {
+		return Claims{}, wrapError(ErrMalformedToken, err)
+	}
+	return jot.Claims.Custom, nil
+}
+
+// userIDOf returns the ID of the user of the token. For the tokens without
+// "uid" claim, i.e. the tokens composed by ComposeToken, "jti" claim is used
+// instead.
+func userIDOf(jot *jwt.JWT[Claims]) string {
+	if jot.Claims.Custom.UserID != "" {
+		return jot.Claims.Custom.UserID
+	}
+	return jot.Claims.JWTID
+}
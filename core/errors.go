@@ -0,0 +1,107 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hiroaki-yamamoto/gauth/audit"
+	"github.com/hiroaki-yamamoto/gauth/config"
+)
+
+// Verification errors
+
+var (
+	// ErrNoToken is returned when the request doesn't have the token.
+	ErrNoToken = errors.New("token not found")
+	// ErrMalformedToken is returned when the token can't be parsed or
+	// decoded.
+	ErrMalformedToken = errors.New("malformed token")
+	// ErrInvalidSignature is returned when the signature of the token is not
+	// valid, i.e. the token is forged or signed with an unknown key.
+	ErrInvalidSignature = errors.New("invalid signature")
+	// ErrExpired is returned when the token is expired.
+	ErrExpired = errors.New("jwt is expired")
+	// ErrNotActive is returned when the token is used before "nbf" claim.
+	ErrNotActive = errors.New("jwt is not active yet")
+	// ErrIssuedInFuture is returned when the token is used before "iat"
+	// claim.
+	ErrIssuedInFuture = errors.New("jwt used before issued")
+	// ErrInvalidAudience is returned when "aud" claim doesn't contain
+	// Config.Audience.
+	ErrInvalidAudience = errors.New("invalid audience")
+	// ErrInvalidIssuer is returned when "iss" claim is not Config.Issuer.
+	ErrInvalidIssuer = errors.New("invalid issuer")
+	// ErrInvalidSubject is returned when "sub" claim is not Config.Subject.
+	ErrInvalidSubject = errors.New("invalid subject")
+	// ErrRevoked is returned when the token is revoked by Config.Revoker.
+	ErrRevoked = errors.New("jwt is revoked")
+	// ErrRefreshAsAccess is returned when a refresh token is used as an
+	// access token.
+	ErrRefreshAsAccess = errors.New(
+		"refresh token can't be used as access token",
+	)
+	// ErrNotRefresh is returned when a token other than a refresh token is
+	// used as a refresh token.
+	ErrNotRefresh = errors.New("the token is not a refresh token")
+	// ErrNotAuthenticated is returned when the token doesn't identify the
+	// user.
+	ErrNotAuthenticated = errors.New("Not authenticated user")
+	// ErrSessionTooOld is returned when the refresh token is used after
+	// Config.MaxSessionAge since the user logged in.
+	ErrSessionTooOld = errors.New("the session is too old to refresh")
+	// ErrStaleToken is returned when the token was issued for an older token
+	// version of the user.
+	ErrStaleToken = errors.New("token version is outdated")
+	// ErrInvalidTenant is returned when "tid" claim is not Config.Tenant,
+	// i.e. the token of another tenant is used.
+	ErrInvalidTenant = errors.New("invalid tenant")
+)
+
+// ValidationError describes the claim that failed the validation.
+// Err is one of the sentinel errors above, so the errors can be checked with
+// errors.Is as well as errors.As:
+//
+//	var verr *core.ValidationError
+//	if errors.As(err, &verr) && errors.Is(verr, core.ErrExpired) {
+//		// The token was valid, but it's expired.
+//	}
+type ValidationError struct {
+	// Claim is the name of the claim, e.g. "exp" or "aud".
+	Claim string
+	// Expected is the value that the claim should match, e.g. the expiration
+	// time for "exp" claim, or Config.Audience for "aud" claim.
+	Expected any
+	// Actual is the value that is compared with Expected, e.g. the current
+	// time for "exp" claim, or the audience of the token for "aud" claim.
+	Actual any
+	// JWTID is "jti" claim of the token.
+	JWTID string
+	// Err is the sentinel error.
+	Err error
+}
+
+func (me *ValidationError) Error() string {
+	return me.Err.Error()
+}
+
+// Unwrap returns the sentinel error.
+func (me *ValidationError) Unwrap() error {
+	return me.Err
+}
+
+// wrapError wraps err with the sentinel error.
+func wrapError(sentinel, err error) error {
+	return fmt.Errorf("%w: %w", sentinel, err)
+}
+
+// emitFailure sends audit.VerificationFailed event of err.
+func emitFailure(conf *config.Config, userID string, err error) {
+	event := audit.Event{
+		Type: audit.VerificationFailed, UserID: userID, Reason: err.Error(),
+	}
+	var verr *ValidationError
+	if errors.As(err, &verr) {
+		event.JWTID = verr.JWTID
+	}
+	conf.Emit(event)
+}
@@ -0,0 +1,81 @@
+package core_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"codeberg.org/gbrlsnchs/jwt"
+	"github.com/hiroaki-yamamoto/gauth/clock"
+	_conf "github.com/hiroaki-yamamoto/gauth/config"
+	"github.com/hiroaki-yamamoto/gauth/core"
+	"github.com/hiroaki-yamamoto/gauth/store"
+	"gotest.tools/v3/assert"
+)
+
+// Verification error test
+
+func TestValidationError(t *testing.T) {
+	fake := clock.NewFake(now)
+	conf := &_conf.Config{
+		Signer:   mustHS256("test secret key"),
+		Audience: "Test Audience",
+		ExpireIn: time.Hour,
+		Clock:    fake,
+	}
+	token, err := core.ComposeID("test_username", conf)
+	assert.NilError(t, err)
+	jot, err := core.ExtractAccess(string(token), conf)
+	assert.NilError(t, err)
+
+	t.Run("Expired", func(t *testing.T) {
+		fake.Set(now.Add(2 * time.Hour))
+		defer fake.Set(now)
+		_, err := core.ExtractToken(string(token), conf)
+		assert.ErrorIs(t, err, core.ErrExpired)
+		var verr *core.ValidationError
+		assert.Assert(t, errors.As(err, &verr))
+		assert.Equal(t, verr.Claim, "exp")
+		assert.Equal(t, verr.Expected, jot.Claims.Expiration.Time())
+		assert.Equal(t, verr.Actual, now.Add(2*time.Hour))
+		assert.Equal(t, verr.JWTID, jot.Claims.JWTID)
+	})
+	t.Run("Invalid audience", func(t *testing.T) {
+		conf := *conf
+		conf.Audience = "Other Audience"
+		_, err := core.ExtractToken(string(token), &conf)
+		assert.ErrorIs(t, err, core.ErrInvalidAudience)
+		var verr *core.ValidationError
+		assert.Assert(t, errors.As(err, &verr))
+		assert.Equal(t, verr.Claim, "aud")
+		assert.Equal(t, verr.Expected, "Other Audience")
+		assert.DeepEqual(t, verr.Actual, jwt.Audience{"Test Audience"})
+	})
+	t.Run("Forged", func(t *testing.T) {
+		conf := *conf
+		conf.Signer = mustHS256("other secret key")
+		_, err := core.ExtractToken(string(token), &conf)
+		assert.ErrorIs(t, err, core.ErrInvalidSignature)
+		var verr *core.ValidationError
+		assert.Assert(t, !errors.As(err, &verr))
+	})
+	t.Run("Malformed", func(t *testing.T) {
+		_, err := core.ExtractToken("not a token", conf)
+		assert.ErrorIs(t, err, core.ErrMalformedToken)
+	})
+	t.Run("Refresh token as access token", func(t *testing.T) {
+		conf, err := _conf.New(
+			"session", _conf.Header, mustHS256("test"),
+			"Test Audience", "Test Issuer", "Test Subject",
+			time.Hour, _conf.CookieConfig{},
+		)
+		assert.NilError(t, err)
+		conf.RefreshStore = store.NewMemoryRefreshStore()
+		pair, err := core.ComposePair("test_username", conf)
+		assert.NilError(t, err)
+		_, err = core.ExtractToken(string(pair.Refresh), conf)
+		assert.ErrorIs(t, err, core.ErrRefreshAsAccess)
+		_, err = core.ExtractRefresh(string(pair.Access), conf)
+		assert.ErrorIs(t, err, core.ErrNotRefresh)
+	})
+}
@@ -0,0 +1,97 @@
+package core
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"codeberg.org/gbrlsnchs/jwt"
+	"github.com/hiroaki-yamamoto/gauth/config"
+)
+
+var (
+	// ErrNoSigner is returned when a token is composed with the Config that
+	// has no Signer, i.e. the Config that only verifies the tokens.
+	ErrNoSigner = errors.New("no signer to compose the token")
+	// ErrNoVerifier is returned when a token is verified with the Config
+	// that has no Verifier and whose Signer doesn't implement jwt.Verifier.
+	ErrNoVerifier = errors.New("Signer does not implement jwt.Verifier")
+)
+
+// KeyedSigner is a jwt.Signer that holds several keys, such as
+// keyset.KeySet. The tokens are signed with the current key, and the ID of
+// the key is stamped to "kid" header.
+type KeyedSigner interface {
+	jwt.Signer
+	// CurrentSigner returns the ID and the signer of the current key.
+	CurrentSigner() (string, jwt.Signer, error)
+}
+
+// KeyedVerifier is a verifier that holds several keys, such as
+// keyset.KeySet. The tokens are verified with the key selected by "kid"
+// header.
+type KeyedVerifier interface {
+	// VerifierFor returns the verifier of the key identified by kid.
+	VerifierFor(kid string) (jwt.Verifier, error)
+}
+
+func sign[T any](jot *jwt.JWT[T], signer jwt.Signer) ([]byte, error) {
+	if signer == nil {
+		return nil, ErrNoSigner
+	}
+	if keyed, ok := signer.(KeyedSigner); ok {
+		kid, current, err := keyed.CurrentSigner()
+		if err != nil {
+			return nil, err
+		}
+		jot.Header.KeyID = kid
+		return jwt.Sign(jot, current)
+	}
+	return jwt.Sign(jot, signer)
+}
+
+// verifierFor returns the verifier of token. The malformed header is
+// reported as ErrMalformedToken, and the unknown or expired key is reported
+// as ErrInvalidSignature.
+func verifierFor(token string, conf *config.Config) (jwt.Verifier, error) {
+	var source any = conf.Signer
+	if conf.Verifier != nil {
+		source = conf.Verifier
+	}
+	if keyed, ok := source.(KeyedVerifier); ok {
+		header, err := decodeHeader(token)
+		if err != nil {
+			return nil, wrapError(ErrMalformedToken, err)
+		}
+		if header.KeyID != "" {
+			verifier, err := keyed.VerifierFor(header.KeyID)
+			if err != nil {
+				return nil, wrapError(ErrInvalidSignature, err)
+			}
+			return verifier, nil
+		}
+	}
+	verifier, ok := source.(jwt.Verifier)
+	if !ok {
+		return nil, ErrNoVerifier
+	}
+	return verifier, nil
+}
+
+// decodeHeader decodes the header of the token without verification.
+func decodeHeader(token string) (*jwt.Header, error) {
+	encoded, _, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, jwt.ErrMalformed
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	var header jwt.Header
+	if err = json.Unmarshal(raw, &header); err != nil {
+		return nil, err
+	}
+	return &header, nil
+}
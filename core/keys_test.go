@@ -0,0 +1,80 @@
+package core_test
+
+import (
+	"testing"
+	"time"
+
+	"codeberg.org/gbrlsnchs/jwt"
+	"github.com/hiroaki-yamamoto/gauth/config"
+	"github.com/hiroaki-yamamoto/gauth/core"
+	"github.com/hiroaki-yamamoto/gauth/keyset"
+	"gotest.tools/v3/assert"
+)
+
+// Key rotation test
+
+func TestKeyRotation(t *testing.T) {
+	now := time.Now().UTC()
+	rotateAt := now.Add(time.Hour)
+	ks, err := keyset.New(
+		keyset.Key{
+			ID: "old", Signer: mustHS256("old"),
+			RetireAt: rotateAt, ExpireAt: rotateAt.Add(time.Hour),
+		},
+		keyset.Key{ID: "new", Signer: mustHS256("new"), NotBefore: rotateAt},
+	)
+	assert.NilError(t, err)
+	conf := &config.Config{Signer: ks, ExpireIn: 2 * time.Hour}
+
+	ks.Clock = TimeMock{now}
+	oldToken, err := core.ComposeID("test_username", conf)
+	assert.NilError(t, err)
+	extracted, err := core.ExtractToken(string(oldToken), conf)
+	assert.NilError(t, err)
+	assert.Equal(t, extracted.Header.KeyID, "old")
+
+	ks.Clock = TimeMock{rotateAt}
+	newToken, err := core.ComposeID("test_username", conf)
+	assert.NilError(t, err)
+	extracted, err = core.ExtractToken(string(newToken), conf)
+	assert.NilError(t, err)
+	assert.Equal(t, extracted.Header.KeyID, "new")
+	_, err = core.ExtractToken(string(oldToken), conf)
+	assert.NilError(t, err)
+
+	t.Run("Retired key", func(t *testing.T) {
+		ks.Clock = TimeMock{rotateAt.Add(time.Hour)}
+		_, err := core.ExtractToken(string(oldToken), conf)
+		assert.ErrorIs(t, err, keyset.ErrUnknownKey)
+		assert.ErrorIs(t, err, core.ErrInvalidSignature)
+	})
+	t.Run("Token without kid", func(t *testing.T) {
+		ks.Clock = TimeMock{rotateAt}
+		token, err := jwt.Sign(GetFixture(), mustHS256("new"))
+		assert.NilError(t, err)
+		_, err = core.ExtractToken(string(token), conf)
+		assert.NilError(t, err)
+	})
+	t.Run("Malformed header", func(t *testing.T) {
+		_, err := core.ExtractToken("!!!.e30.", conf)
+		assert.ErrorIs(t, err, core.ErrMalformedToken)
+	})
+}
+
+func TestVerifyOnly(t *testing.T) {
+	signer := mustHS256("test")
+	token, err := core.ComposeID(
+		"test_username", &config.Config{Signer: signer, ExpireIn: time.Hour},
+	)
+	assert.NilError(t, err)
+	conf := &config.Config{Verifier: signer.(jwt.Verifier), ExpireIn: time.Hour}
+
+	_, err = core.ExtractToken(string(token), conf)
+	assert.NilError(t, err)
+	_, err = core.ComposeID("test_username", conf)
+	assert.ErrorIs(t, err, core.ErrNoSigner)
+
+	conf.Verifier = nil
+	_, err = core.ExtractToken(string(token), conf)
+	assert.ErrorIs(t, err, core.ErrNoVerifier)
+}
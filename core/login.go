@@ -4,9 +4,11 @@ import (
 	"net/http"
 	"time"
 
-	"github.com/hiroaki-yamamoto/gauth/clock"
+	"codeberg.org/gbrlsnchs/jwt"
+	"github.com/hiroaki-yamamoto/gauth/audit"
 	"github.com/hiroaki-yamamoto/gauth/config"
 	"github.com/hiroaki-yamamoto/gauth/models"
+	"github.com/hiroaki-yamamoto/gauth/store"
 )
 
 // Login sets the specified user to the session field that is specified on the
@@ -15,24 +17,112 @@ func Login(
 	w http.ResponseWriter,
 	conf *config.Config, user models.IUser,
 ) error {
-	token, err := ComposeID(user.GetID(), conf)
+	return LoginWithClaims(w, conf, user, jwt.None{})
+}
+
+// LoginWithClaims is the same as Login, but it also embeds custom as
+// private claims like ComposeClaims. In Session mode, a new session is
+// created on Config.SessionStore instead, and custom is ignored.
+func LoginWithClaims[T any](
+	w http.ResponseWriter,
+	conf *config.Config, user models.IUser, custom T,
+) error {
+	return login(w, nil, conf, user, custom)
+}
+
+// LoginRequest is the same as Login, but it also records User-Agent header
+// and the address of the client of r to the session on
+// Config.SessionStore, so that the user can tell the devices apart in
+// ListSessions.
+func LoginRequest(
+	w http.ResponseWriter,
+	r *http.Request,
+	conf *config.Config, user models.IUser,
+) error {
+	return login(w, r, conf, user, jwt.None{})
+}
+
+// LoginRequestWithClaims is the combination of LoginRequest and
+// LoginWithClaims.
+func LoginRequestWithClaims[T any](
+	w http.ResponseWriter,
+	r *http.Request,
+	conf *config.Config, user models.IUser, custom T,
+) error {
+	return login(w, r, conf, user, custom)
+}
+
+// login starts a new session of user. If Config.SessionStore is set, the
+// session is recorded there, and the token refers to it by "sid" claim.
+func login[T any](
+	w http.ResponseWriter,
+	r *http.Request,
+	conf *config.Config, user models.IUser, custom T,
+) error {
+	if conf.MiddlewareType == config.Session {
+		return startSession(w, r, conf, user)
+	}
+	now := conf.Now()
+	claims := claimsOf(user)
+	if conf.SessionStore != nil {
+		session, err := recordSession(r, conf, &store.Session{
+			UserID: claims.UserID, CreatedAt: now,
+			ExpireAt: expireAt(now, now, conf.ExpireIn, conf),
+		})
+		if err != nil {
+			return err
+		}
+		claims.SessionID = session.ID
+	}
+	jti, err := setClaims(w, conf, claims, custom, now, now)
 	if err != nil {
-		return err
+		return discardSession(conf, claims.SessionID, err)
 	}
-	if conf.MiddlewareType == config.Header {
-		w.Header().Add("X-"+conf.SessionName, string(token))
-		return nil
+	conf.Emit(audit.Event{
+		Type: audit.Login, UserID: claims.UserID, JWTID: jti,
+		SessionID: claims.SessionID,
+	})
+	return nil
+}
+
+// setClaims composes the token with claims of the session that started at
+// authTime and sets it to the session field. The ID of the token is
+// returned.
+func setClaims[T any](
+	w http.ResponseWriter,
+	conf *config.Config, claims Claims, custom T,
+	now, authTime time.Time,
+) (string, error) {
+	jti, exp, token, err := composeClaims(
+		claims, custom, now, authTime, conf,
+	)
+	if err != nil {
+		return "", err
+	}
+	setToken(w, conf, conf.SessionName, token, exp.Sub(now))
+	return jti, nil
+}
+
+func setToken(
+	w http.ResponseWriter,
+	conf *config.Config,
+	name string,
+	token []byte,
+	expireIn time.Duration,
+) {
+	if !conf.MiddlewareType.UsesCookie() {
+		w.Header().Add("X-"+name, string(token))
+		return
 	}
 	http.SetCookie(w, &http.Cookie{
-		Name:     conf.SessionName,
+		Name:     name,
 		Value:    string(token),
 		Path:     conf.Path,
 		Domain:   conf.Domain,
-		Expires:  clock.Clock.Now().Add(conf.ExpireIn),
-		MaxAge:   int(conf.ExpireIn / time.Second),
+		Expires:  conf.Now().Add(expireIn),
+		MaxAge:   int(expireIn / time.Second),
 		Secure:   conf.Secure,
 		HttpOnly: conf.HTTPOnly,
 		SameSite: conf.SameSite,
 	})
-	return nil
 }
@@ -47,8 +47,7 @@ func performLogin(conf *config.Config) (
 }
 
 func TestCookieLogin(t *testing.T) {
-	clock.Clock = TimeMock{time.Unix(time.Now().Unix(), 0).UTC()}
-	now := clock.Clock.Now()
+	now := time.Unix(time.Now().Unix(), 0).UTC()
 	conf, err := config.New(
 		"session", config.Cookie, mustHS256("test"),
 		"Test Audience", "Test Issuer", "Test Subject",
@@ -61,6 +60,7 @@ func TestCookieLogin(t *testing.T) {
 		},
 	)
 	assert.NilError(t, err)
+	conf.Clock = clock.NewFake(now)
 	rec, user, err := performLogin(conf)
 	assert.NilError(t, err)
 	var session *http.Cookie
@@ -80,9 +80,9 @@ func TestCookieLogin(t *testing.T) {
 	assert.Equal(t, session.Expires, now.Add(conf.ExpireIn))
 	assert.Equal(t, session.MaxAge, int(conf.ExpireIn/time.Second))
 
-	parsedToken, err := core.ExtractToken(session.Value, conf)
+	parsedToken, err := core.ExtractAccess(session.Value, conf)
 	assert.NilError(t, err)
-	assert.Equal(t, parsedToken.Claims.JWTID, user.Username)
+	assert.Equal(t, parsedToken.Claims.Custom.UserID, user.Username)
 
 	t.Run("Invalid token generation case", func(t *testing.T) {
 		conf.Signer = mockSigner{}
@@ -92,19 +92,19 @@ func TestCookieLogin(t *testing.T) {
 }
 
 func TestHeaderLogin(t *testing.T) {
-	clock.Clock = TimeMock{time.Unix(time.Now().Unix(), 0).UTC()}
 	conf, err := config.New(
 		"Auth", config.Header, mustHS256("test"),
 		"Test Audience", "Test Issuer", "Test Subject",
 		3600*time.Minute, config.CookieConfig{},
 	)
 	assert.NilError(t, err)
+	conf.Clock = clock.NewFake(time.Unix(time.Now().Unix(), 0).UTC())
 	rec, user, err := performLogin(conf)
 	assert.NilError(t, err)
 	session := rec.Header().Get("X-" + conf.SessionName)
-	parsedToken, err := core.ExtractToken(session, conf)
+	parsedToken, err := core.ExtractAccess(session, conf)
 	assert.NilError(t, err)
-	assert.Equal(t, parsedToken.Claims.JWTID, user.Username)
+	assert.Equal(t, parsedToken.Claims.Custom.UserID, user.Username)
 
 	t.Run("Invalid token generation case", func(t *testing.T) {
 		conf.Signer = mockSigner{}
@@ -0,0 +1,109 @@
+package core
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/hiroaki-yamamoto/gauth/audit"
+	"github.com/hiroaki-yamamoto/gauth/config"
+)
+
+// Logout revokes the token in the session field that is specified on the
+// config, and clears the session field. The session of the token is deleted
+// from Config.SessionStore as well. If the request also has a refresh
+// token, its token family is revoked as well.
+//
+// The tokens are revoked only if Config.Revoker / Config.RefreshStore are
+// set. Invalid or missing tokens are not regarded as errors because there's
+// nothing to revoke; only the errors from the stores are returned.
+func Logout(
+	w http.ResponseWriter,
+	r *http.Request,
+	conf *config.Config,
+) error {
+	event := audit.Event{Type: audit.Logout, RemoteAddr: r.RemoteAddr}
+	token := tokenFromRequest(r, conf, conf.SessionName)
+	if token != "" && conf.MiddlewareType == config.Session {
+		userID, err := endSession(token, conf)
+		if err != nil {
+			return err
+		}
+		event.UserID, event.SessionID = userID, token
+	} else if token != "" {
+		jot, err := ExtractAccess(token, conf)
+		if err == nil {
+			event.UserID, event.JWTID = userIDOf(jot), jot.Claims.JWTID
+			event.SessionID = jot.Claims.Custom.SessionID
+		}
+		if err == nil && event.SessionID != "" {
+			if _, err = endSession(event.SessionID, conf); err != nil {
+				return err
+			}
+		}
+		if err == nil && conf.Revoker != nil {
+			// The token is accepted until Config.Leeway after it expires,
+			// so the entry must be kept until then.
+			err = conf.Revoker.Revoke(
+				jot.Claims.JWTID,
+				jot.Claims.Expiration.Time().Add(conf.Leeway),
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	clearToken(w, conf, conf.SessionName)
+
+	name := conf.RefreshSessionName()
+	if token := tokenFromRequest(r, conf, name); token != "" {
+		jot, err := ExtractRefresh(token, conf)
+		if err == nil && event.UserID == "" {
+			event.UserID = jot.Claims.Custom.UserID
+		}
+		if err == nil && conf.RefreshStore != nil {
+			err = conf.RefreshStore.RevokeFamily(jot.Claims.Custom.Family)
+			if err != nil {
+				return err
+			}
+		}
+		clearToken(w, conf, name)
+	}
+	conf.Emit(event)
+	return nil
+}
+
+func tokenFromRequest(
+	r *http.Request,
+	conf *config.Config,
+	name string,
+) string {
+	if conf.MiddlewareType == config.Bearer && name == conf.SessionName {
+		return BearerToken(r)
+	}
+	if !conf.MiddlewareType.UsesCookie() {
+		return r.Header.Get(name)
+	}
+	c, err := r.Cookie(name)
+	if err != nil {
+		return ""
+	}
+	return c.Value
+}
+
+func clearToken(w http.ResponseWriter, conf *config.Config, name string) {
+	if !conf.MiddlewareType.UsesCookie() {
+		w.Header().Set("X-"+name, "")
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    "",
+		Path:     conf.Path,
+		Domain:   conf.Domain,
+		Expires:  conf.Now().Add(-24 * time.Hour),
+		MaxAge:   -1,
+		Secure:   conf.Secure,
+		HttpOnly: conf.HTTPOnly,
+		SameSite: conf.SameSite,
+	})
+}
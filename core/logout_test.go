@@ -0,0 +1,107 @@
+package core_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hiroaki-yamamoto/gauth/clock"
+	"github.com/hiroaki-yamamoto/gauth/config"
+	"github.com/hiroaki-yamamoto/gauth/core"
+	"github.com/hiroaki-yamamoto/gauth/store"
+	"gotest.tools/v3/assert"
+)
+
+// Logout test
+
+func TestCookieLogout(t *testing.T) {
+	conf, err := config.New(
+		"session", config.Cookie, mustHS256("test"),
+		"Test Audience", "Test Issuer", "Test Subject",
+		time.Hour, config.CookieConfig{Path: "/"},
+	)
+	assert.NilError(t, err)
+	conf.RefreshStore = store.NewMemoryRefreshStore()
+	conf.Revoker = store.NewMemoryRevoker()
+	pair, err := core.ComposePair("test_username", conf)
+	assert.NilError(t, err)
+
+	req := httptest.NewRequest("POST", "/logout", nil)
+	req.AddCookie(&http.Cookie{Name: conf.SessionName, Value: string(pair.Access)})
+	req.AddCookie(&http.Cookie{
+		Name: conf.RefreshSessionName(), Value: string(pair.Refresh),
+	})
+	rec := httptest.NewRecorder()
+	assert.NilError(t, core.Logout(rec, req, conf))
+
+	cookies := rec.Result().Cookies()
+	assert.Equal(t, len(cookies), 2)
+	for _, cookie := range cookies {
+		assert.Equal(t, cookie.Value, "")
+		assert.Equal(t, cookie.MaxAge, -1)
+	}
+	_, err = core.ExtractToken(string(pair.Access), conf)
+	assert.Error(t, err, "jwt is revoked")
+	refresh, err := core.ExtractRefresh(string(pair.Refresh), conf)
+	assert.NilError(t, err)
+	_, err = core.RotatePair(refresh, conf)
+	assert.ErrorIs(t, err, store.ErrUnknownFamily)
+}
+
+func TestHeaderLogout(t *testing.T) {
+	conf, err := config.New(
+		"session", config.Header, mustHS256("test"),
+		"Test Audience", "Test Issuer", "Test Subject",
+		time.Hour, config.CookieConfig{},
+	)
+	assert.NilError(t, err)
+	conf.Revoker = store.NewMemoryRevoker()
+	access, err := core.ComposeID("test_username", conf)
+	assert.NilError(t, err)
+
+	t.Run("Valid token", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/logout", nil)
+		req.Header.Set(conf.SessionName, string(access))
+		rec := httptest.NewRecorder()
+		assert.NilError(t, core.Logout(rec, req, conf))
+		values := rec.Header().Values("X-" + conf.SessionName)
+		assert.DeepEqual(t, values, []string{""})
+		_, err = core.ExtractToken(string(access), conf)
+		assert.Error(t, err, "jwt is revoked")
+	})
+	t.Run("Invalid token", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/logout", nil)
+		req.Header.Set(conf.SessionName, "invalid token")
+		rec := httptest.NewRecorder()
+		assert.NilError(t, core.Logout(rec, req, conf))
+		assert.Equal(t, conf.Revoker.(*store.MemoryRevoker).Len(), 1)
+	})
+}
+
+func TestLogoutWithLeeway(t *testing.T) {
+	now := clock.NewFake(time.Now().UTC().Truncate(time.Second))
+	conf, err := config.New(
+		"session", config.Header, mustHS256("test"),
+		"Test Audience", "Test Issuer", "Test Subject",
+		time.Hour, config.CookieConfig{},
+	)
+	assert.NilError(t, err)
+	conf.Clock, conf.Leeway = now, 5*time.Minute
+	revoker := store.NewMemoryRevoker()
+	revoker.Clock = now
+	conf.Revoker = revoker
+	access, err := core.ComposeID("test_username", conf)
+	assert.NilError(t, err)
+
+	req := httptest.NewRequest("POST", "/logout", nil)
+	req.Header.Set(conf.SessionName, string(access))
+	assert.NilError(t, core.Logout(httptest.NewRecorder(), req, conf))
+	now.Advance(conf.ExpireIn + time.Minute)
+	_, err = core.ExtractToken(string(access), conf)
+	assert.ErrorIs(t, err, core.ErrRevoked)
+
+	now.Advance(conf.Leeway)
+	_, err = core.ExtractToken(string(access), conf)
+	assert.ErrorIs(t, err, core.ErrExpired)
+}
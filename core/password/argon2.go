@@ -0,0 +1,94 @@
+package password
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Argon2id is the parameters of argon2id (RFC 9106).
+type Argon2id struct {
+	// Time is the number of passes over the memory.
+	Time uint32
+	// Memory is the size of the memory in KiB.
+	Memory uint32
+	// Threads is the degree of parallelism.
+	Threads uint8
+	// KeyLen is the length of the hash in bytes.
+	KeyLen uint32
+	// SaltLen is the length of the salt in bytes.
+	SaltLen uint32
+}
+
+// DefaultArgon2id is the second recommended option of RFC 9106 with 64 MiB
+// of memory.
+var DefaultArgon2id = Argon2id{
+	Time: 3, Memory: 64 * 1024, Threads: 4, KeyLen: 32, SaltLen: 16,
+}
+
+const argon2idPrefix = "$argon2id$"
+
+// Hash implements Hasher.
+func (me Argon2id) Hash(password string) (string, error) {
+	salt, err := newSalt(int(me.SaltLen))
+	if err != nil {
+		return "", err
+	}
+	hash := argon2.IDKey(
+		[]byte(password), salt, me.Time, me.Memory, me.Threads, me.KeyLen,
+	)
+	return encodePHC(
+		"argon2id", argon2.Version,
+		fmt.Sprintf("m=%d,t=%d,p=%d", me.Memory, me.Time, me.Threads),
+		salt, hash,
+	), nil
+}
+
+func (me Argon2id) decode(encoded string) (*phc, *Argon2id, error) {
+	decoded, err := decodePHC(encoded)
+	if err != nil {
+		return nil, nil, err
+	}
+	if decoded.id != "argon2id" {
+		return nil, nil, ErrUnknownAlgorithm
+	}
+	if decoded.version != argon2.Version {
+		return nil, nil, fmt.Errorf(
+			"%w: unsupported argon2 version %d", ErrMalformedHash, decoded.version,
+		)
+	}
+	m, t, p := decoded.params["m"], decoded.params["t"], decoded.params["p"]
+	if m == 0 || t == 0 || p == 0 || p > 255 {
+		return nil, nil, fmt.Errorf("%w: invalid argon2 parameters", ErrMalformedHash)
+	}
+	return decoded, &Argon2id{
+		Time: uint32(t), Memory: uint32(m), Threads: uint8(p),
+		KeyLen: uint32(len(decoded.hash)), SaltLen: uint32(len(decoded.salt)),
+	}, nil
+}
+
+// Verify implements Hasher.
+func (me Argon2id) Verify(password, encoded string) (bool, error) {
+	decoded, params, err := me.decode(encoded)
+	if err != nil {
+		return false, err
+	}
+	hash := argon2.IDKey(
+		[]byte(password), decoded.salt,
+		params.Time, params.Memory, params.Threads, params.KeyLen,
+	)
+	return subtle.ConstantTimeCompare(hash, decoded.hash) == 1, nil
+}
+
+// CanVerify implements Hasher.
+func (me Argon2id) CanVerify(encoded string) bool {
+	return strings.HasPrefix(encoded, argon2idPrefix)
+}
+
+// NeedsRehash implements Hasher.
+func (me Argon2id) NeedsRehash(encoded string) bool {
+	_, params, err := me.decode(encoded)
+	return err != nil || *params != me
+}
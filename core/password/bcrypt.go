@@ -0,0 +1,60 @@
+package password
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Bcrypt is the parameters of bcrypt. Note that bcrypt uses only the first
+// 72 bytes of the password; longer passwords are rejected by Hash.
+type Bcrypt struct {
+	// Cost is the base-2 logarithm of the number of the iterations.
+	Cost int
+}
+
+// DefaultBcrypt is the parameters that OWASP recommends (cost=10) plus two
+// rounds of margin.
+var DefaultBcrypt = Bcrypt{Cost: 12}
+
+// Hash implements Hasher.
+func (me Bcrypt) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), me.Cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// Verify implements Hasher.
+func (me Bcrypt) Verify(password, encoded string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", ErrMalformedHash, err)
+	}
+	return true, nil
+}
+
+// CanVerify implements Hasher.
+func (me Bcrypt) CanVerify(encoded string) bool {
+	for _, prefix := range []string{"$2a$", "$2b$", "$2y$"} {
+		if strings.HasPrefix(encoded, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// NeedsRehash implements Hasher.
+func (me Bcrypt) NeedsRehash(encoded string) bool {
+	if !me.CanVerify(encoded) {
+		return true
+	}
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost != me.Cost
+}
@@ -0,0 +1,62 @@
+package password
+
+// Transparent hash migration
+
+// Upgrade is called with the fresh hash of the password when the password is
+// verified against a hash that needs rehash. Save the hash to the user in
+// this function.
+type Upgrade func(hash string) error
+
+// Chain verifies the passwords against the hashes of Current and the legacy
+// algorithms, and upgrades the hashes that are not made with Current.
+type Chain struct {
+	// Current is the algorithm that the hashes are upgraded to.
+	Current Hasher
+	// Legacy is the list of the algorithms that are recognised in addition
+	// to Current. The first one that can verify the hash is used.
+	Legacy []Hasher
+}
+
+// NewChain creates a new Chain that upgrades the hashes to DefaultArgon2id.
+// If legacy is empty, PBKDF2-SHA256 (the formats of passlib and Django),
+// bcrypt and scrypt are recognised.
+func NewChain(legacy ...Hasher) Chain {
+	if len(legacy) == 0 {
+		legacy = []Hasher{DefaultPBKDF2SHA256, DefaultBcrypt, DefaultScrypt}
+	}
+	return Chain{Current: DefaultArgon2id, Legacy: legacy}
+}
+
+// Verify returns true if password matches the encoded hash of Current or any
+// of Legacy. If it matches and the hash needs rehash, password is hashed with
+// Current and upgrade is called with the fresh hash. The error of upgrade is
+// returned as-is, but the first value is still true in this case because the
+// password itself is correct. upgrade can be nil to skip the upgrade.
+//
+// ErrUnknownAlgorithm is returned if no algorithm can verify the hash.
+func (me Chain) Verify(
+	password, encoded string, upgrade Upgrade,
+) (bool, error) {
+	var hasher Hasher
+	for _, candidate := range append([]Hasher{me.Current}, me.Legacy...) {
+		if candidate.CanVerify(encoded) {
+			hasher = candidate
+			break
+		}
+	}
+	if hasher == nil {
+		return false, ErrUnknownAlgorithm
+	}
+	ok, err := hasher.Verify(password, encoded)
+	if err != nil || !ok {
+		return false, err
+	}
+	if upgrade == nil || !me.Current.NeedsRehash(encoded) {
+		return true, nil
+	}
+	hash, err := me.Current.Hash(password)
+	if err != nil {
+		return true, err
+	}
+	return true, upgrade(hash)
+}
@@ -0,0 +1,105 @@
+package password_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hiroaki-yamamoto/gauth/core/password"
+	"gotest.tools/v3/assert"
+)
+
+// Hash migration test
+
+const (
+	djangoHash  = "pbkdf2_sha256$1000$seasalt$mQnueSakb748zqBAC1tmWVZsZbi2zPGZarEzTGdfmso="
+	passlibHash = "$pbkdf2-sha256$1000$MDEyMzQ1Njc4OWFiY2RlZg$cBg8D2DungRB9k76szThf5ehfyBz991ay6PT8Srwk4M"
+)
+
+func TestPBKDF2SHA256(t *testing.T) {
+	hasher := password.PBKDF2SHA256{Iterations: 1000, KeyLen: 32, SaltLen: 16}
+	for name, encoded := range map[string]string{
+		"Django":  djangoHash,
+		"passlib": passlibHash,
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Assert(t, hasher.CanVerify(encoded))
+			ok, err := hasher.Verify("correct horse", encoded)
+			assert.NilError(t, err)
+			assert.Assert(t, ok)
+			ok, err = hasher.Verify("wrong horse", encoded)
+			assert.NilError(t, err)
+			assert.Assert(t, !ok)
+		})
+	}
+	t.Run("Hash", func(t *testing.T) {
+		encoded, err := hasher.Hash("correct horse")
+		assert.NilError(t, err)
+		ok, err := hasher.Verify("correct horse", encoded)
+		assert.NilError(t, err)
+		assert.Assert(t, ok)
+		assert.Assert(t, !hasher.NeedsRehash(encoded))
+		assert.Assert(t, hasher.NeedsRehash(djangoHash))
+	})
+	t.Run("Malformed", func(t *testing.T) {
+		_, err := hasher.Verify("correct horse", "pbkdf2_sha256$x$salt$aGFzaA==")
+		assert.ErrorIs(t, err, password.ErrMalformedHash)
+	})
+}
+
+func TestChain(t *testing.T) {
+	chain := password.NewChain()
+	chain.Current = fastArgon2id
+	bcryptHash, err := fastBcrypt.Hash("correct horse")
+	assert.NilError(t, err)
+
+	for name, encoded := range map[string]string{
+		"Django PBKDF2":  djangoHash,
+		"passlib PBKDF2": passlibHash,
+		"bcrypt":         bcryptHash,
+	} {
+		t.Run(name, func(t *testing.T) {
+			var upgraded string
+			ok, err := chain.Verify(
+				"correct horse", encoded, func(hash string) error {
+					upgraded = hash
+					return nil
+				},
+			)
+			assert.NilError(t, err)
+			assert.Assert(t, ok)
+			assert.Assert(t, !fastArgon2id.NeedsRehash(upgraded))
+			ok, err = fastArgon2id.Verify("correct horse", upgraded)
+			assert.NilError(t, err)
+			assert.Assert(t, ok)
+		})
+	}
+	t.Run("Wrong password is not upgraded", func(t *testing.T) {
+		ok, err := chain.Verify("wrong horse", djangoHash, func(string) error {
+			t.Fatal("upgrade must not be called")
+			return nil
+		})
+		assert.NilError(t, err)
+		assert.Assert(t, !ok)
+	})
+	t.Run("Current hash is not upgraded", func(t *testing.T) {
+		encoded, err := fastArgon2id.Hash("correct horse")
+		assert.NilError(t, err)
+		ok, err := chain.Verify("correct horse", encoded, func(string) error {
+			t.Fatal("upgrade must not be called")
+			return nil
+		})
+		assert.NilError(t, err)
+		assert.Assert(t, ok)
+	})
+	t.Run("Upgrade error", func(t *testing.T) {
+		ok, err := chain.Verify("correct horse", djangoHash, func(string) error {
+			return errors.New("upgrade error")
+		})
+		assert.Error(t, err, "upgrade error")
+		assert.Assert(t, ok)
+	})
+	t.Run("Unknown algorithm", func(t *testing.T) {
+		_, err := password.NewChain(fastBcrypt).Verify("x", djangoHash, nil)
+		assert.ErrorIs(t, err, password.ErrUnknownAlgorithm)
+	})
+}
@@ -0,0 +1,157 @@
+// Package password provides password hashing functions that are easy to use.
+//
+// Hash hashes a password with argon2id and the parameters of
+// DefaultArgon2id, and Verify verifies a password against a hash of any
+// supported algorithm in constant time. The hashes are encoded in the PHC
+// string format (bcrypt uses its own modular crypt format), so that the
+// algorithm and the parameters are embedded in the hash itself:
+//
+//	$argon2id$v=19$m=65536,t=3,p=4$<salt>$<hash>
+//	$scrypt$ln=17,r=8,p=1$<salt>$<hash>
+//	$2a$12$<salt and hash>
+//
+// NeedsRehash reports whether a hash was made with an algorithm or
+// parameters other than the current ones. Rehash the password on a
+// successful login in that case to upgrade the hashes transparently:
+//
+//	ok, err := password.Verify(input, user.PasswordHash)
+//	if err != nil || !ok {
+//		return errNotAuthorized
+//	}
+//	if password.NeedsRehash(user.PasswordHash) {
+//		user.PasswordHash, err = password.Hash(input)
+//		// Save the user...
+//	}
+package password
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrUnknownAlgorithm is returned when the algorithm of the hash is not
+	// supported.
+	ErrUnknownAlgorithm = errors.New("unknown password hash algorithm")
+	// ErrMalformedHash is returned when the hash can't be decoded.
+	ErrMalformedHash = errors.New("malformed password hash")
+)
+
+// Hasher is a password hashing algorithm with its parameters.
+type Hasher interface {
+	// Hash hashes password with a random salt, and returns the encoded hash.
+	Hash(password string) (string, error)
+	// Verify returns true if password matches the encoded hash. The hash is
+	// decoded with the parameters embedded in it, not the ones of the
+	// receiver.
+	Verify(password, encoded string) (bool, error)
+	// CanVerify returns true if the encoded hash is made with the algorithm
+	// of the receiver.
+	CanVerify(encoded string) bool
+	// NeedsRehash returns true if the encoded hash is not made with the
+	// algorithm and the parameters of the receiver.
+	NeedsRehash(encoded string) bool
+}
+
+// hashers is the list of the supported algorithms that Verify uses.
+var hashers = []Hasher{DefaultArgon2id, DefaultScrypt, DefaultBcrypt}
+
+// Hash hashes password with DefaultArgon2id.
+func Hash(password string) (string, error) {
+	return DefaultArgon2id.Hash(password)
+}
+
+// Verify returns true if password matches the encoded hash of any supported
+// algorithm. ErrUnknownAlgorithm is returned if the algorithm is not
+// supported.
+func Verify(password, encoded string) (bool, error) {
+	for _, hasher := range hashers {
+		if hasher.CanVerify(encoded) {
+			return hasher.Verify(password, encoded)
+		}
+	}
+	return false, ErrUnknownAlgorithm
+}
+
+// NeedsRehash returns true if the encoded hash is not made with
+// DefaultArgon2id.
+func NeedsRehash(encoded string) bool {
+	return DefaultArgon2id.NeedsRehash(encoded)
+}
+
+func newSalt(size int) ([]byte, error) {
+	salt := make([]byte, size)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+var b64 = base64.RawStdEncoding
+
+// phc is a decoded PHC string:
+// $<id>[$v=<version>]$<param>=<value>[,<param>=<value>...]$<salt>$<hash>
+type phc struct {
+	id      string
+	version int
+	params  map[string]int
+	salt    []byte
+	hash    []byte
+}
+
+func encodePHC(
+	id string, version int, params string, salt, hash []byte,
+) string {
+	encoded := "$" + id
+	if version != 0 {
+		encoded += "$v=" + strconv.Itoa(version)
+	}
+	return encoded + "$" + params + "$" +
+		b64.EncodeToString(salt) + "$" + b64.EncodeToString(hash)
+}
+
+func decodePHC(encoded string) (*phc, error) {
+	fields := strings.Split(encoded, "$")
+	if len(fields) < 5 || fields[0] != "" {
+		return nil, ErrMalformedHash
+	}
+	decoded := &phc{id: fields[1], params: map[string]int{}}
+	fields = fields[2:]
+	if strings.HasPrefix(fields[0], "v=") {
+		version, err := strconv.Atoi(fields[0][2:])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrMalformedHash, err)
+		}
+		decoded.version = version
+		fields = fields[1:]
+	}
+	if len(fields) != 3 {
+		return nil, ErrMalformedHash
+	}
+	for _, param := range strings.Split(fields[0], ",") {
+		name, value, ok := strings.Cut(param, "=")
+		if !ok {
+			return nil, ErrMalformedHash
+		}
+		num, err := strconv.Atoi(value)
+		if err != nil || num < 0 {
+			return nil, fmt.Errorf("%w: invalid %s", ErrMalformedHash, name)
+		}
+		decoded.params[name] = num
+	}
+	var err error
+	if decoded.salt, err = b64.DecodeString(fields[1]); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedHash, err)
+	}
+	if decoded.hash, err = b64.DecodeString(fields[2]); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedHash, err)
+	}
+	if len(decoded.hash) == 0 {
+		return nil, ErrMalformedHash
+	}
+	return decoded, nil
+}
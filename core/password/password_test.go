@@ -0,0 +1,96 @@
+package password_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hiroaki-yamamoto/gauth/core/password"
+	"gotest.tools/v3/assert"
+)
+
+// Password hashing test
+
+// Cheap parameters to keep the tests fast.
+var (
+	fastArgon2id = password.Argon2id{
+		Time: 1, Memory: 64, Threads: 1, KeyLen: 32, SaltLen: 16,
+	}
+	fastScrypt = password.Scrypt{LogN: 4, R: 8, P: 1, KeyLen: 32, SaltLen: 16}
+	fastBcrypt = password.Bcrypt{Cost: 4}
+)
+
+func TestHashers(t *testing.T) {
+	for name, hasher := range map[string]password.Hasher{
+		"argon2id": fastArgon2id,
+		"scrypt":   fastScrypt,
+		"bcrypt":   fastBcrypt,
+	} {
+		t.Run(name, func(t *testing.T) {
+			encoded, err := hasher.Hash("correct horse")
+			assert.NilError(t, err)
+			assert.Assert(t, hasher.CanVerify(encoded))
+			assert.Assert(t, !hasher.NeedsRehash(encoded))
+
+			ok, err := hasher.Verify("correct horse", encoded)
+			assert.NilError(t, err)
+			assert.Assert(t, ok)
+			ok, err = hasher.Verify("wrong horse", encoded)
+			assert.NilError(t, err)
+			assert.Assert(t, !ok)
+
+			ok, err = password.Verify("correct horse", encoded)
+			assert.NilError(t, err)
+			assert.Assert(t, ok)
+			assert.Assert(t, password.NeedsRehash(encoded))
+
+			another, err := hasher.Hash("correct horse")
+			assert.NilError(t, err)
+			assert.Assert(t, another != encoded, "salt must be random")
+		})
+	}
+}
+
+func TestArgon2idEncoding(t *testing.T) {
+	encoded, err := fastArgon2id.Hash("password")
+	assert.NilError(t, err)
+	assert.Assert(t, strings.HasPrefix(encoded, "$argon2id$v=19$m=64,t=1,p=1$"))
+
+	t.Run("Parameters are read from the hash", func(t *testing.T) {
+		stronger := fastArgon2id
+		stronger.Time = 2
+		assert.Assert(t, stronger.NeedsRehash(encoded))
+		ok, err := stronger.Verify("password", encoded)
+		assert.NilError(t, err)
+		assert.Assert(t, ok)
+	})
+	t.Run("Default", func(t *testing.T) {
+		encoded, err := password.Hash("password")
+		assert.NilError(t, err)
+		assert.Assert(t, strings.HasPrefix(
+			encoded, "$argon2id$v=19$m=65536,t=3,p=4$",
+		))
+		assert.Assert(t, !password.NeedsRehash(encoded))
+	})
+}
+
+func TestMalformedHash(t *testing.T) {
+	for name, encoded := range map[string]string{
+		"Too few fields":   "$argon2id$v=19$m=64,t=1,p=1$c2FsdA",
+		"Unknown version":  "$argon2id$v=16$m=64,t=1,p=1$c2FsdA$aGFzaA",
+		"Zero parameter":   "$argon2id$v=19$m=64,t=0,p=1$c2FsdA$aGFzaA",
+		"Invalid base64":   "$argon2id$v=19$m=64,t=1,p=1$!!!$aGFzaA",
+		"Invalid param":    "$scrypt$ln=x,r=8,p=1$c2FsdA$aGFzaA",
+		"Missing param":    "$scrypt$r=8,p=1$c2FsdA$aGFzaA",
+		"Malformed bcrypt": "$2a$04$short",
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := password.Verify("password", encoded)
+			assert.ErrorIs(t, err, password.ErrMalformedHash)
+			assert.Assert(t, password.NeedsRehash(encoded))
+		})
+	}
+	t.Run("Unknown algorithm", func(t *testing.T) {
+		_, err := password.Verify("password", "$md5$c2FsdA$aGFzaA")
+		assert.ErrorIs(t, err, password.ErrUnknownAlgorithm)
+	})
+}
@@ -0,0 +1,133 @@
+package password
+
+import (
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PBKDF2SHA256 is the parameters of PBKDF2 with HMAC-SHA256 (RFC 8018).
+// This is supported to migrate the hashes of the other systems; use
+// Argon2id for the new hashes.
+//
+// Hash encodes the hash in the format of passlib:
+//
+//	$pbkdf2-sha256$<iterations>$<salt>$<hash>
+//
+// where the salt and the hash are encoded with the adapted base64 of
+// passlib, i.e. "." is used instead of "+" and the padding is omitted.
+// Verify also accepts the format of Django:
+//
+//	pbkdf2_sha256$<iterations>$<salt>$<hash>
+//
+// where the salt is a plain string and the hash is encoded with the standard
+// base64.
+type PBKDF2SHA256 struct {
+	// Iterations is the number of the iterations.
+	Iterations int
+	// KeyLen is the length of the hash in bytes.
+	KeyLen int
+	// SaltLen is the length of the salt in bytes.
+	SaltLen int
+}
+
+// DefaultPBKDF2SHA256 is the parameters that OWASP recommends.
+var DefaultPBKDF2SHA256 = PBKDF2SHA256{
+	Iterations: 600000, KeyLen: 32, SaltLen: 16,
+}
+
+const (
+	passlibPBKDF2Prefix = "$pbkdf2-sha256$"
+	djangoPBKDF2Prefix  = "pbkdf2_sha256$"
+)
+
+var passlibB64 = base64.NewEncoding(
+	"ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789./",
+).WithPadding(base64.NoPadding)
+
+// Hash implements Hasher.
+func (me PBKDF2SHA256) Hash(password string) (string, error) {
+	salt, err := newSalt(me.SaltLen)
+	if err != nil {
+		return "", err
+	}
+	hash, err := pbkdf2.Key(sha256.New, password, salt, me.Iterations, me.KeyLen)
+	if err != nil {
+		return "", err
+	}
+	return passlibPBKDF2Prefix + strconv.Itoa(me.Iterations) + "$" +
+		passlibB64.EncodeToString(salt) + "$" +
+		passlibB64.EncodeToString(hash), nil
+}
+
+func (me PBKDF2SHA256) decode(encoded string) (
+	iterations int, salt, hash []byte, err error,
+) {
+	var fields []string
+	var decode func(string) ([]byte, error)
+	switch {
+	case strings.HasPrefix(encoded, passlibPBKDF2Prefix):
+		fields = strings.Split(encoded[len(passlibPBKDF2Prefix):], "$")
+		decode = passlibB64.DecodeString
+	case strings.HasPrefix(encoded, djangoPBKDF2Prefix):
+		fields = strings.Split(encoded[len(djangoPBKDF2Prefix):], "$")
+		decode = func(salt string) ([]byte, error) { return []byte(salt), nil }
+	default:
+		return 0, nil, nil, ErrUnknownAlgorithm
+	}
+	if len(fields) != 3 {
+		return 0, nil, nil, ErrMalformedHash
+	}
+	iterations, err = strconv.Atoi(fields[0])
+	if err != nil || iterations < 1 {
+		return 0, nil, nil, fmt.Errorf("%w: invalid iterations", ErrMalformedHash)
+	}
+	if salt, err = decode(fields[1]); err != nil {
+		return 0, nil, nil, fmt.Errorf("%w: %v", ErrMalformedHash, err)
+	}
+	if strings.HasPrefix(encoded, djangoPBKDF2Prefix) {
+		hash, err = base64.StdEncoding.DecodeString(fields[2])
+	} else {
+		hash, err = decode(fields[2])
+	}
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("%w: %v", ErrMalformedHash, err)
+	}
+	if len(hash) == 0 {
+		return 0, nil, nil, ErrMalformedHash
+	}
+	return iterations, salt, hash, nil
+}
+
+// Verify implements Hasher.
+func (me PBKDF2SHA256) Verify(password, encoded string) (bool, error) {
+	iterations, salt, expected, err := me.decode(encoded)
+	if err != nil {
+		return false, err
+	}
+	hash, err := pbkdf2.Key(sha256.New, password, salt, iterations, len(expected))
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare(hash, expected) == 1, nil
+}
+
+// CanVerify implements Hasher.
+func (me PBKDF2SHA256) CanVerify(encoded string) bool {
+	return strings.HasPrefix(encoded, passlibPBKDF2Prefix) ||
+		strings.HasPrefix(encoded, djangoPBKDF2Prefix)
+}
+
+// NeedsRehash implements Hasher.
+func (me PBKDF2SHA256) NeedsRehash(encoded string) bool {
+	if !strings.HasPrefix(encoded, passlibPBKDF2Prefix) {
+		return true
+	}
+	iterations, salt, hash, err := me.decode(encoded)
+	return err != nil || iterations != me.Iterations ||
+		len(salt) != me.SaltLen || len(hash) != me.KeyLen
+}
@@ -0,0 +1,90 @@
+package password
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"math/bits"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// Scrypt is the parameters of scrypt (RFC 7914).
+type Scrypt struct {
+	// LogN is the base-2 logarithm of the CPU/memory cost parameter N.
+	LogN uint8
+	// R is the block size parameter.
+	R int
+	// P is the parallelization parameter.
+	P int
+	// KeyLen is the length of the hash in bytes.
+	KeyLen int
+	// SaltLen is the length of the salt in bytes.
+	SaltLen int
+}
+
+// DefaultScrypt is the parameters that OWASP recommends (N=2^17, r=8, p=1).
+var DefaultScrypt = Scrypt{LogN: 17, R: 8, P: 1, KeyLen: 32, SaltLen: 16}
+
+const scryptPrefix = "$scrypt$"
+
+// Hash implements Hasher.
+func (me Scrypt) Hash(password string) (string, error) {
+	salt, err := newSalt(me.SaltLen)
+	if err != nil {
+		return "", err
+	}
+	hash, err := scrypt.Key([]byte(password), salt, 1<<me.LogN, me.R, me.P, me.KeyLen)
+	if err != nil {
+		return "", err
+	}
+	return encodePHC(
+		"scrypt", 0, fmt.Sprintf("ln=%d,r=%d,p=%d", me.LogN, me.R, me.P),
+		salt, hash,
+	), nil
+}
+
+func (me Scrypt) decode(encoded string) (*phc, *Scrypt, error) {
+	decoded, err := decodePHC(encoded)
+	if err != nil {
+		return nil, nil, err
+	}
+	if decoded.id != "scrypt" {
+		return nil, nil, ErrUnknownAlgorithm
+	}
+	ln, r, p := decoded.params["ln"], decoded.params["r"], decoded.params["p"]
+	if ln == 0 || ln >= bits.UintSize-1 || r == 0 || p == 0 {
+		return nil, nil, fmt.Errorf("%w: invalid scrypt parameters", ErrMalformedHash)
+	}
+	return decoded, &Scrypt{
+		LogN: uint8(ln), R: r, P: p,
+		KeyLen: len(decoded.hash), SaltLen: len(decoded.salt),
+	}, nil
+}
+
+// Verify implements Hasher.
+func (me Scrypt) Verify(password, encoded string) (bool, error) {
+	decoded, params, err := me.decode(encoded)
+	if err != nil {
+		return false, err
+	}
+	hash, err := scrypt.Key(
+		[]byte(password), decoded.salt,
+		1<<params.LogN, params.R, params.P, params.KeyLen,
+	)
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare(hash, decoded.hash) == 1, nil
+}
+
+// CanVerify implements Hasher.
+func (me Scrypt) CanVerify(encoded string) bool {
+	return strings.HasPrefix(encoded, scryptPrefix)
+}
+
+// NeedsRehash implements Hasher.
+func (me Scrypt) NeedsRehash(encoded string) bool {
+	_, params, err := me.decode(encoded)
+	return err != nil || *params != me
+}
@@ -0,0 +1,263 @@
+package core
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"codeberg.org/gbrlsnchs/jwt"
+	"github.com/hiroaki-yamamoto/gauth/audit"
+	"github.com/hiroaki-yamamoto/gauth/config"
+	"github.com/hiroaki-yamamoto/gauth/models"
+	"github.com/hiroaki-yamamoto/gauth/store"
+)
+
+// refreshTokenType is the "typ" header of refresh tokens. This prevents a
+// refresh token from being accepted as an access token and vice versa.
+const refreshTokenType = "refresh+jwt"
+
+// TokenPair is a pair of an access token and a refresh token.
+type TokenPair struct {
+	// Access is the short-lived token that authenticates the requests.
+	Access []byte
+	// Refresh is the long-lived token that is exchanged for a new TokenPair.
+	Refresh []byte
+}
+
+func composeRefresh(
+	claims Claims,
+	authTime time.Time,
+	conf *config.Config,
+) (string, time.Time, []byte, error) {
+	now := conf.Now()
+	exp := expireAt(now, authTime, conf.RefreshLifetime(), conf)
+	var aud jwt.Audience
+	if conf.Audience != "" {
+		aud = jwt.Audience{conf.Audience}
+	}
+	jti := newTokenID()
+	claims.AuthTime = jwt.ConvertTime(authTime)
+	claims.Tenant = conf.Tenant
+	jot := &jwt.JWT[Claims]{
+		Header: jwt.Header{Type: refreshTokenType},
+		Claims: jwt.Claims[Claims]{
+			Issuer:     conf.Issuer,
+			Subject:    conf.Subject,
+			Audience:   aud,
+			Expiration: jwt.ConvertTime(exp),
+			NotBefore:  jwt.ConvertTime(now),
+			IssuedAt:   jwt.ConvertTime(now),
+			JWTID:      jti,
+			Custom:     claims,
+		},
+	}
+	token, err := sign(jot, conf.Signer)
+	return jti, exp, token, err
+}
+
+// ComposePair generates a new TokenPair for the specified ID. The refresh
+// token starts a new token family that is registered to
+// Config.RefreshStore. If Config.SessionStore is set, the pair is recorded
+// as a session that lives as long as the refresh token, and both tokens
+// refer to it by "sid" claim.
+func ComposePair(ID string, conf *config.Config) (*TokenPair, error) {
+	return composePair(Claims{UserID: ID}, conf)
+}
+
+// ComposeUserPair is the same as ComposePair, but it takes the user, and
+// also embeds the token version if user implements models.TokenVersioner.
+func ComposeUserPair(
+	user models.IUser,
+	conf *config.Config,
+) (*TokenPair, error) {
+	return composePair(claimsOf(user), conf)
+}
+
+func composePair(claims Claims, conf *config.Config) (*TokenPair, error) {
+	if conf.RefreshStore == nil {
+		return nil, errors.New("RefreshStore is not configured")
+	}
+	ID := claims.UserID
+	now := conf.Now()
+	claims.Family = newTokenID()
+	if conf.SessionStore != nil {
+		session, err := recordSession(nil, conf, &store.Session{
+			UserID: ID, CreatedAt: now, Family: claims.Family,
+			ExpireAt: expireAt(now, now, conf.RefreshLifetime(), conf),
+		})
+		if err != nil {
+			return nil, err
+		}
+		claims.SessionID = session.ID
+	}
+	jti, exp, refresh, err := composeRefresh(claims, now, conf)
+	if err != nil {
+		return nil, discardSession(conf, claims.SessionID, err)
+	}
+	access := claims
+	access.Family = ""
+	accessID, _, token, err := composeClaims(
+		access, jwt.None{}, now, now, conf,
+	)
+	if err != nil {
+		return nil, discardSession(conf, claims.SessionID, err)
+	}
+	if err = conf.RefreshStore.Issue(claims.Family, jti, exp); err != nil {
+		return nil, discardSession(conf, claims.SessionID, err)
+	}
+	conf.Emit(audit.Event{
+		Type: audit.Login, UserID: ID, JWTID: accessID,
+		SessionID: claims.SessionID,
+	})
+	return &TokenPair{token, refresh}, nil
+}
+
+// ExtractRefresh extracts refresh token string into verified JWT object.
+// Note that this function doesn't check whether the token is the latest one
+// of its family. RotatePair does.
+func ExtractRefresh(
+	token string,
+	conf *config.Config,
+) (*jwt.JWT[Claims], error) {
+	jot, err := extractRefresh(token, conf)
+	if err != nil {
+		emitFailure(conf, "", err)
+		return nil, err
+	}
+	return jot, nil
+}
+
+func extractRefresh(
+	token string,
+	conf *config.Config,
+) (*jwt.JWT[Claims], error) {
+	jot, err := extract[Claims](token, conf)
+	if err != nil {
+		return nil, err
+	}
+	if jot.Header.Type != refreshTokenType {
+		return nil, &ValidationError{
+			Claim: "typ", Expected: refreshTokenType, Actual: jot.Header.Type,
+			JWTID: jot.Claims.JWTID, Err: ErrNotRefresh,
+		}
+	}
+	if jot.Claims.Custom.UserID == "" {
+		return nil, &ValidationError{
+			Claim: "uid", JWTID: jot.Claims.JWTID, Err: ErrNotAuthenticated,
+		}
+	}
+	if jot.Claims.Custom.Family == "" {
+		return nil, &ValidationError{
+			Claim: "fam", JWTID: jot.Claims.JWTID, Err: ErrNotAuthenticated,
+		}
+	}
+	return jot, nil
+}
+
+// checkRefreshSession returns ErrRevoked as ValidationError if the session
+// of the refresh token is revoked, i.e. deleted from Config.SessionStore.
+// extract checks this as well, but RotatePair checks it again because the
+// session may be revoked after the refresh token is extracted.
+func checkRefreshSession(jot *jwt.JWT[Claims], conf *config.Config) error {
+	sid := jot.Claims.Custom.SessionID
+	if sid == "" || conf.SessionStore == nil {
+		return nil
+	}
+	_, err := conf.SessionStore.Get(sid)
+	if errors.Is(err, store.ErrSessionNotFound) {
+		return &ValidationError{
+			Claim: "sid", Actual: sid, JWTID: jot.Claims.JWTID, Err: ErrRevoked,
+		}
+	}
+	return err
+}
+
+// RotatePair exchanges the refresh token extracted by ExtractRefresh for a
+// new TokenPair. If the refresh token has already been exchanged, the whole
+// token family is revoked and store.ErrReused is returned.
+// The new tokens keep the login time and the token version of the refresh
+// token, so the session can't be refreshed after Config.MaxSessionAge since
+// the user logged in.
+func RotatePair(
+	refresh *jwt.JWT[Claims],
+	conf *config.Config,
+) (*TokenPair, error) {
+	if conf.RefreshStore == nil {
+		return nil, errors.New("RefreshStore is not configured")
+	}
+	ID := refresh.Claims.Custom.UserID
+	family := refresh.Claims.Custom.Family
+	authTime := authTimeOf(refresh.Claims.Custom, refresh.Claims.IssuedAt)
+	now := conf.Now()
+	if limit := authTime.Add(conf.MaxSessionAge); conf.MaxSessionAge > 0 &&
+		!now.Before(limit) {
+		err := &ValidationError{
+			Claim: "auth_time", Expected: limit, Actual: now,
+			JWTID: refresh.Claims.JWTID, Err: ErrSessionTooOld,
+		}
+		emitFailure(conf, ID, err)
+		return nil, err
+	}
+	sid := refresh.Claims.Custom.SessionID
+	if err := checkRefreshSession(refresh, conf); err != nil {
+		emitFailure(conf, ID, err)
+		return nil, err
+	}
+	claims := Claims{
+		UserID: ID, TokenVersion: refresh.Claims.Custom.TokenVersion,
+		SessionID: sid,
+	}
+	accessID, _, access, err := composeClaims(
+		claims, jwt.None{}, now, authTime, conf,
+	)
+	if err != nil {
+		return nil, err
+	}
+	claims.Family = family
+	jti, exp, token, err := composeRefresh(claims, authTime, conf)
+	if err != nil {
+		return nil, err
+	}
+	err = conf.RefreshStore.Rotate(family, refresh.Claims.JWTID, jti, exp)
+	if err != nil {
+		conf.Emit(audit.Event{
+			Type: audit.VerificationFailed, UserID: ID,
+			JWTID: refresh.Claims.JWTID, Reason: err.Error(),
+			SessionID: sid,
+		})
+		return nil, err
+	}
+	if sid != "" && conf.SessionStore != nil {
+		if err = extendSession(sid, exp, conf); err != nil {
+			return nil, err
+		}
+	}
+	conf.Emit(audit.Event{
+		Type: audit.Refresh, UserID: ID, JWTID: accessID, SessionID: sid,
+	})
+	return &TokenPair{access, token}, nil
+}
+
+// SetPair sets the specified TokenPair to the session fields that are
+// specified on the config.
+func SetPair(w http.ResponseWriter, conf *config.Config, pair *TokenPair) {
+	setToken(w, conf, conf.SessionName, pair.Access, conf.ExpireIn)
+	setToken(
+		w, conf, conf.RefreshSessionName(),
+		pair.Refresh, conf.RefreshLifetime(),
+	)
+}
+
+// LoginWithRefresh is the same as Login, but it also issues a refresh token
+// that starts a new token family.
+func LoginWithRefresh(
+	w http.ResponseWriter,
+	conf *config.Config, user models.IUser,
+) error {
+	pair, err := ComposeUserPair(user, conf)
+	if err != nil {
+		return err
+	}
+	SetPair(w, conf, pair)
+	return nil
+}
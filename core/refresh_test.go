@@ -0,0 +1,133 @@
+package core_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hiroaki-yamamoto/gauth/config"
+	"github.com/hiroaki-yamamoto/gauth/core"
+	"github.com/hiroaki-yamamoto/gauth/store"
+	"gotest.tools/v3/assert"
+)
+
+// Refresh token test
+
+func TestComposePair(t *testing.T) {
+	conf, err := config.New(
+		"session", config.Cookie, mustHS256("test"),
+		"Test Audience", "Test Issuer", "Test Subject",
+		time.Hour, config.CookieConfig{Path: "/"},
+	)
+	assert.NilError(t, err)
+	conf.RefreshStore = store.NewMemoryRefreshStore()
+	pair, err := core.ComposePair("test_username", conf)
+	assert.NilError(t, err)
+
+	access, err := core.ExtractAccess(string(pair.Access), conf)
+	assert.NilError(t, err)
+	assert.Equal(t, access.Claims.Custom.UserID, "test_username")
+
+	refresh, err := core.ExtractRefresh(string(pair.Refresh), conf)
+	assert.NilError(t, err)
+	assert.Equal(t, refresh.Claims.Custom.UserID, "test_username")
+	assert.Assert(t, refresh.Claims.Custom.Family != "")
+	assert.Equal(
+		t, refresh.Claims.Expiration.Time().Sub(refresh.Claims.IssuedAt.Time()),
+		config.DefaultRefreshExpireIn,
+	)
+
+	t.Run("Refresh token is not an access token", func(t *testing.T) {
+		_, err := core.ExtractToken(string(pair.Refresh), conf)
+		assert.ErrorContains(t, err, "refresh token can't be used")
+	})
+	t.Run("Access token is not a refresh token", func(t *testing.T) {
+		_, err := core.ExtractRefresh(string(pair.Access), conf)
+		assert.Error(t, err, "the token is not a refresh token")
+	})
+	t.Run("Without store", func(t *testing.T) {
+		conf, err := config.New(
+			"session", config.Cookie, mustHS256("test"),
+			"Test Audience", "Test Issuer", "Test Subject",
+			time.Hour, config.CookieConfig{Path: "/"},
+		)
+		assert.NilError(t, err)
+		_, err = core.ComposePair("test_username", conf)
+		assert.Error(t, err, "RefreshStore is not configured")
+	})
+}
+
+func TestRotatePair(t *testing.T) {
+	conf, err := config.New(
+		"session", config.Cookie, mustHS256("test"),
+		"Test Audience", "Test Issuer", "Test Subject",
+		time.Hour, config.CookieConfig{Path: "/"},
+	)
+	assert.NilError(t, err)
+	conf.RefreshStore = store.NewMemoryRefreshStore()
+	first, err := core.ComposePair("test_username", conf)
+	assert.NilError(t, err)
+	firstRefresh, err := core.ExtractRefresh(string(first.Refresh), conf)
+	assert.NilError(t, err)
+
+	second, err := core.RotatePair(firstRefresh, conf)
+	assert.NilError(t, err)
+	secondRefresh, err := core.ExtractRefresh(string(second.Refresh), conf)
+	assert.NilError(t, err)
+	assert.Equal(
+		t, secondRefresh.Claims.Custom.Family, firstRefresh.Claims.Custom.Family,
+	)
+	assert.Assert(t, secondRefresh.Claims.JWTID != firstRefresh.Claims.JWTID)
+
+	t.Run("Reuse revokes the family", func(t *testing.T) {
+		_, err := core.RotatePair(firstRefresh, conf)
+		assert.ErrorIs(t, err, store.ErrReused)
+		_, err = core.RotatePair(secondRefresh, conf)
+		assert.ErrorIs(t, err, store.ErrUnknownFamily)
+	})
+}
+
+func TestLoginWithRefresh(t *testing.T) {
+	t.Run("Cookie", func(t *testing.T) {
+		conf, err := config.New(
+			"session", config.Cookie, mustHS256("test"),
+			"Test Audience", "Test Issuer", "Test Subject",
+			time.Hour, config.CookieConfig{Path: "/"},
+		)
+		assert.NilError(t, err)
+		conf.RefreshStore = store.NewMemoryRefreshStore()
+		rec := httptest.NewRecorder()
+		err = core.LoginWithRefresh(rec, conf, User{Username: "test_username"})
+		assert.NilError(t, err)
+		cookies := map[string]*http.Cookie{}
+		for _, cookie := range rec.Result().Cookies() {
+			cookies[cookie.Name] = cookie
+		}
+		assert.Assert(t, cookies[conf.SessionName] != nil)
+		refresh := cookies[conf.RefreshSessionName()]
+		assert.Assert(t, refresh != nil)
+		assert.Equal(
+			t, refresh.MaxAge, int(conf.RefreshLifetime()/time.Second),
+		)
+		_, err = core.ExtractRefresh(refresh.Value, conf)
+		assert.NilError(t, err)
+	})
+	t.Run("Header", func(t *testing.T) {
+		conf, err := config.New(
+			"session", config.Header, mustHS256("test"),
+			"Test Audience", "Test Issuer", "Test Subject",
+			time.Hour, config.CookieConfig{},
+		)
+		assert.NilError(t, err)
+		conf.RefreshStore = store.NewMemoryRefreshStore()
+		conf.RefreshName = "Refresh"
+		rec := httptest.NewRecorder()
+		err = core.LoginWithRefresh(rec, conf, User{Username: "test_username"})
+		assert.NilError(t, err)
+		_, err = core.ExtractToken(rec.Header().Get("X-"+conf.SessionName), conf)
+		assert.NilError(t, err)
+		_, err = core.ExtractRefresh(rec.Header().Get("X-Refresh"), conf)
+		assert.NilError(t, err)
+	})
+}
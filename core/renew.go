@@ -0,0 +1,77 @@
+package core
+
+import (
+	"net/http"
+	"time"
+
+	"codeberg.org/gbrlsnchs/jwt"
+	"github.com/hiroaki-yamamoto/gauth/audit"
+	"github.com/hiroaki-yamamoto/gauth/config"
+	"github.com/hiroaki-yamamoto/gauth/models"
+)
+
+// Renew re-issues current, the token of user extracted by ExtractClaims,
+// according to Config.RenewalPolicy, and sets the new token to the session
+// field like Login. The custom claims, the scopes and the login time of
+// current are kept, so the new token never expires after
+// Config.MaxSessionAge since the user logged in.
+//
+// The first value is true if the token is re-issued. It is false when the
+// policy doesn't require the renewal, when Config.MaxSessionAge doesn't
+// let the new token live longer than current, or when Config has no Signer,
+// i.e. it only verifies the tokens.
+// If current is nil, this function is the same as LoginWithClaims.
+func Renew[T any](
+	w http.ResponseWriter,
+	conf *config.Config,
+	user models.IUser,
+	current *jwt.JWT[CustomClaims[T]],
+) (bool, error) {
+	now := conf.Now()
+	if current == nil {
+		var custom T
+		return true, LoginWithClaims(w, conf, user, custom)
+	}
+	if conf.Signer == nil ||
+		!needsRenewal(current.Claims.Expiration.Time().Sub(now), conf) {
+		return false, nil
+	}
+	authTime := authTimeOf(current.Claims.Custom.Claims, current.Claims.IssuedAt)
+	exp := expireAt(now, authTime, conf.ExpireIn, conf)
+	if conf.MaxSessionAge > 0 &&
+		jwt.ConvertTime(exp) <= current.Claims.Expiration {
+		return false, nil
+	}
+	claims := claimsOf(user)
+	claims.SessionID = current.Claims.Custom.SessionID
+	claims.Scope = current.Claims.Custom.Scope
+	if claims.SessionID != "" && conf.SessionStore != nil {
+		if err := extendSession(claims.SessionID, exp, conf); err != nil {
+			return false, err
+		}
+	}
+	jti, err := setClaims(
+		w, conf, claims, current.Claims.Custom.Custom, now, authTime,
+	)
+	if err != nil {
+		return false, err
+	}
+	conf.Emit(audit.Event{
+		Type: audit.Renew, UserID: claims.UserID, JWTID: jti,
+		SessionID: claims.SessionID,
+	})
+	return true, nil
+}
+
+// needsRenewal returns true if the policy requires the renewal of the token
+// that expires in remaining.
+func needsRenewal(remaining time.Duration, conf *config.Config) bool {
+	switch conf.RenewalPolicy {
+	case config.RenewNever:
+		return false
+	case config.RenewBeforeExpiry:
+		return remaining < conf.RenewThreshold
+	default:
+		return true
+	}
+}
@@ -0,0 +1,215 @@
+package core_test
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"codeberg.org/gbrlsnchs/jwt"
+	"github.com/hiroaki-yamamoto/gauth/clock"
+	"github.com/hiroaki-yamamoto/gauth/config"
+	"github.com/hiroaki-yamamoto/gauth/core"
+	"github.com/hiroaki-yamamoto/gauth/store"
+	"gotest.tools/v3/assert"
+)
+
+// Token renewal test
+
+type renewClaims struct {
+	Role string `json:"role"`
+}
+
+// issuedToken returns the token that was issued at iat, and that the user
+// logged in at authTime.
+func issuedToken(
+	authTime, iat time.Time, lifetime time.Duration,
+) *jwt.JWT[core.CustomClaims[renewClaims]] {
+	return &jwt.JWT[core.CustomClaims[renewClaims]]{
+		Claims: jwt.Claims[core.CustomClaims[renewClaims]]{
+			Expiration: jwt.ConvertTime(iat.Add(lifetime)),
+			IssuedAt:   jwt.ConvertTime(iat),
+			JWTID:      "old",
+			Custom: core.CustomClaims[renewClaims]{
+				Claims: core.Claims{
+					UserID:   "test_username",
+					AuthTime: jwt.ConvertTime(authTime),
+				},
+				Custom: renewClaims{Role: "admin"},
+			},
+		},
+	}
+}
+
+func renewedToken(
+	t *testing.T,
+	rec *httptest.ResponseRecorder,
+	conf *config.Config,
+) *jwt.JWT[core.CustomClaims[renewClaims]] {
+	token := rec.Header().Get("X-" + conf.SessionName)
+	assert.Assert(t, token != "")
+	jot, err := core.ExtractClaims[core.CustomClaims[renewClaims]](token, conf)
+	assert.NilError(t, err)
+	return jot
+}
+
+func TestRenew(t *testing.T) {
+	user := User{Username: "test_username"}
+	now := time.Now()
+
+	t.Run("Always", func(t *testing.T) {
+		conf, err := config.New(
+			"session", config.Header, mustHS256("test"),
+			"Test Audience", "Test Issuer", "Test Subject",
+			time.Hour, config.CookieConfig{},
+		)
+		assert.NilError(t, err)
+		conf.Clock = clock.NewFake(now)
+		authTime := now.Add(-2 * time.Hour)
+		rec := httptest.NewRecorder()
+		renewed, err := core.Renew(
+			rec, conf, user, issuedToken(authTime, now.Add(-time.Minute), time.Hour),
+		)
+		assert.NilError(t, err)
+		assert.Assert(t, renewed)
+		jot := renewedToken(t, rec, conf)
+		assert.Equal(t, jot.Claims.Custom.UserID, "test_username")
+		assert.Equal(t, jot.Claims.Custom.Custom.Role, "admin")
+		assert.Equal(t, jot.Claims.Custom.AuthTime, jwt.ConvertTime(authTime))
+		assert.Assert(t, jot.Claims.JWTID != "old")
+	})
+	t.Run("Never", func(t *testing.T) {
+		conf, err := config.New(
+			"session", config.Header, mustHS256("test"),
+			"Test Audience", "Test Issuer", "Test Subject",
+			time.Hour, config.CookieConfig{},
+		)
+		assert.NilError(t, err)
+		conf.Clock = clock.NewFake(now)
+		conf.RenewalPolicy = config.RenewNever
+		rec := httptest.NewRecorder()
+		renewed, err := core.Renew(
+			rec, conf, user, issuedToken(now, now, time.Minute),
+		)
+		assert.NilError(t, err)
+		assert.Assert(t, !renewed)
+		assert.Equal(t, rec.Header().Get("X-"+conf.SessionName), "")
+	})
+	t.Run("Before expiry", func(t *testing.T) {
+		conf, err := config.New(
+			"session", config.Header, mustHS256("test"),
+			"Test Audience", "Test Issuer", "Test Subject",
+			time.Hour, config.CookieConfig{},
+		)
+		assert.NilError(t, err)
+		conf.Clock = clock.NewFake(now)
+		conf.RenewalPolicy = config.RenewBeforeExpiry
+		conf.RenewThreshold = 10 * time.Minute
+
+		rec := httptest.NewRecorder()
+		renewed, err := core.Renew(
+			rec, conf, user, issuedToken(now, now, time.Hour),
+		)
+		assert.NilError(t, err)
+		assert.Assert(t, !renewed)
+		assert.Equal(t, rec.Header().Get("X-"+conf.SessionName), "")
+
+		rec = httptest.NewRecorder()
+		renewed, err = core.Renew(
+			rec, conf, user, issuedToken(now, now.Add(-55*time.Minute), time.Hour),
+		)
+		assert.NilError(t, err)
+		assert.Assert(t, renewed)
+		jot := renewedToken(t, rec, conf)
+		assert.Equal(
+			t, jot.Claims.Expiration, jwt.ConvertTime(now.Add(conf.ExpireIn)),
+		)
+	})
+	t.Run("Max session age caps the expiration", func(t *testing.T) {
+		conf, err := config.New(
+			"session", config.Header, mustHS256("test"),
+			"Test Audience", "Test Issuer", "Test Subject",
+			time.Hour, config.CookieConfig{},
+		)
+		assert.NilError(t, err)
+		conf.Clock = clock.NewFake(now)
+		conf.MaxSessionAge = 90 * time.Minute
+		authTime := now.Add(-time.Hour)
+		rec := httptest.NewRecorder()
+		renewed, err := core.Renew(
+			rec, conf, user,
+			issuedToken(authTime, now.Add(-50*time.Minute), time.Hour),
+		)
+		assert.NilError(t, err)
+		assert.Assert(t, renewed)
+		jot := renewedToken(t, rec, conf)
+		assert.Equal(
+			t, jot.Claims.Expiration,
+			jwt.ConvertTime(authTime.Add(conf.MaxSessionAge)),
+		)
+	})
+	t.Run("Max session age reached", func(t *testing.T) {
+		conf, err := config.New(
+			"session", config.Header, mustHS256("test"),
+			"Test Audience", "Test Issuer", "Test Subject",
+			time.Hour, config.CookieConfig{},
+		)
+		assert.NilError(t, err)
+		conf.Clock = clock.NewFake(now)
+		conf.MaxSessionAge = time.Hour
+		authTime := now.Add(-30 * time.Minute)
+		rec := httptest.NewRecorder()
+		renewed, err := core.Renew(
+			rec, conf, user,
+			issuedToken(authTime, now.Add(-time.Minute), 31*time.Minute),
+		)
+		assert.NilError(t, err)
+		assert.Assert(t, !renewed)
+		assert.Equal(t, rec.Header().Get("X-"+conf.SessionName), "")
+	})
+	t.Run("Legacy token uses iat as the login time", func(t *testing.T) {
+		conf, err := config.New(
+			"session", config.Header, mustHS256("test"),
+			"Test Audience", "Test Issuer", "Test Subject",
+			time.Hour, config.CookieConfig{},
+		)
+		assert.NilError(t, err)
+		conf.Clock = clock.NewFake(now)
+		conf.MaxSessionAge = 90 * time.Minute
+		iat := now.Add(-time.Hour)
+		current := issuedToken(iat, iat, time.Hour)
+		current.Claims.Custom.AuthTime = 0
+		rec := httptest.NewRecorder()
+		renewed, err := core.Renew(rec, conf, user, current)
+		assert.NilError(t, err)
+		assert.Assert(t, renewed)
+		jot := renewedToken(t, rec, conf)
+		assert.Equal(t, jot.Claims.Custom.AuthTime, jwt.ConvertTime(iat))
+		assert.Equal(
+			t, jot.Claims.Expiration, jwt.ConvertTime(iat.Add(conf.MaxSessionAge)),
+		)
+	})
+}
+
+func TestRotatePairMaxSessionAge(t *testing.T) {
+	conf, err := config.New(
+		"session", config.Header, mustHS256("test"),
+		"Test Audience", "Test Issuer", "Test Subject",
+		time.Hour, config.CookieConfig{},
+	)
+	assert.NilError(t, err)
+	conf.RefreshStore = store.NewMemoryRefreshStore()
+	conf.MaxSessionAge = time.Hour
+	pair, err := core.ComposePair("test_username", conf)
+	assert.NilError(t, err)
+	refresh, err := core.ExtractRefresh(string(pair.Refresh), conf)
+	assert.NilError(t, err)
+	assert.Assert(
+		t, refresh.Claims.Expiration <= jwt.ConvertTime(time.Now().Add(time.Hour)),
+	)
+
+	refresh.Claims.Custom.AuthTime = jwt.ConvertTime(
+		time.Now().Add(-2 * time.Hour),
+	)
+	_, err = core.RotatePair(refresh, conf)
+	assert.Error(t, err, "the session is too old to refresh")
+}
@@ -0,0 +1,65 @@
+package core
+
+// OAuth2 scopes
+
+import (
+	"strings"
+
+	"codeberg.org/gbrlsnchs/jwt"
+	"github.com/hiroaki-yamamoto/gauth/config"
+)
+
+// ComposeScopes is the same as ComposeID, but it also embeds scopes as
+// "scope" claim, i.e. the space-delimited list of RFC 8693.
+func ComposeScopes(
+	ID string,
+	scopes []string,
+	config *config.Config,
+) ([]byte, error) {
+	now := config.Now()
+	claims := Claims{UserID: ID, Scope: strings.Join(scopes, " ")}
+	_, _, token, err := composeClaims(claims, jwt.None{}, now, now, config)
+	return token, err
+}
+
+// Scopes returns the scopes in "scope" claim.
+func (me Claims) Scopes() []string {
+	return strings.Fields(me.Scope)
+}
+
+// ScopeMatches returns true if the granted scope allows the required one.
+// The scopes are hierarchical with ":" as the separator. A "*" segment of
+// granted matches any segment of required, and the trailing "*" matches
+// the rest of the segments. For example, "orders:*" allows "orders:read"
+// and "orders:items:write", and "*" allows any scope.
+func ScopeMatches(granted, required string) bool {
+	grantedSegs := strings.Split(granted, ":")
+	requiredSegs := strings.Split(required, ":")
+	for i, seg := range grantedSegs {
+		if seg == "*" && i == len(grantedSegs)-1 {
+			return len(requiredSegs) > i
+		}
+		if i >= len(requiredSegs) || (seg != "*" && seg != requiredSegs[i]) {
+			return false
+		}
+	}
+	return len(grantedSegs) == len(requiredSegs)
+}
+
+// MissingScopes returns the scopes of required that none of granted allows.
+func MissingScopes(granted, required []string) []string {
+	var missing []string
+	for _, scope := range required {
+		allowed := false
+		for _, grant := range granted {
+			if ScopeMatches(grant, scope) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			missing = append(missing, scope)
+		}
+	}
+	return missing
+}
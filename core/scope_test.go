@@ -0,0 +1,63 @@
+package core_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hiroaki-yamamoto/gauth/config"
+	"github.com/hiroaki-yamamoto/gauth/core"
+	"gotest.tools/v3/assert"
+)
+
+// OAuth2 scope test
+
+func TestComposeScopes(t *testing.T) {
+	conf, err := config.New(
+		"session", config.Header, mustHS256("test"),
+		"Test Audience", "Test Issuer", "Test Subject",
+		time.Hour, config.CookieConfig{},
+	)
+	assert.NilError(t, err)
+	token, err := core.ComposeScopes(
+		"test_username", []string{"orders:read", "profile"}, conf,
+	)
+	assert.NilError(t, err)
+	jot, err := core.ExtractAccess(string(token), conf)
+	assert.NilError(t, err)
+	assert.Equal(t, jot.Claims.Custom.Scope, "orders:read profile")
+	assert.DeepEqual(
+		t, jot.Claims.Custom.Scopes(), []string{"orders:read", "profile"},
+	)
+}
+
+func TestScopeMatches(t *testing.T) {
+	cases := []struct {
+		granted, required string
+		expected          bool
+	}{
+		{"orders:read", "orders:read", true},
+		{"orders:read", "orders:write", false},
+		{"orders:*", "orders:read", true},
+		{"orders:*", "orders:items:write", true},
+		{"orders:*", "orders", false},
+		{"orders", "orders:read", false},
+		{"*", "profile", true},
+		{"*:read", "orders:read", true},
+		{"*:read", "orders:write", false},
+		{"*:read", "orders:items:read", false},
+	}
+	for _, c := range cases {
+		assert.Equal(
+			t, core.ScopeMatches(c.granted, c.required), c.expected,
+			"%s -> %s", c.granted, c.required,
+		)
+	}
+}
+
+func TestMissingScopes(t *testing.T) {
+	missing := core.MissingScopes(
+		[]string{"orders:*", "profile"},
+		[]string{"orders:read", "profile", "admin"},
+	)
+	assert.DeepEqual(t, missing, []string{"admin"})
+}
@@ -0,0 +1,260 @@
+package core
+
+// Server-side session mode
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/hiroaki-yamamoto/gauth/audit"
+	"github.com/hiroaki-yamamoto/gauth/config"
+	"github.com/hiroaki-yamamoto/gauth/models"
+	"github.com/hiroaki-yamamoto/gauth/store"
+)
+
+// ErrNoSessionStore is returned in Session mode when Config.SessionStore is
+// not set.
+var ErrNoSessionStore = errors.New("SessionStore is not configured")
+
+// recordSession saves session, a new session that started at
+// session.CreatedAt, to Config.SessionStore with a new ID. If r is not nil,
+// the device of the user is recorded from r.
+func recordSession(
+	r *http.Request,
+	conf *config.Config,
+	session *store.Session,
+) (*store.Session, error) {
+	session.ID, session.Tenant = newTokenID(), conf.Tenant
+	if session.Data == nil {
+		session.Data = map[string]string{}
+	}
+	if r != nil {
+		session.UserAgent, session.RemoteAddr = r.UserAgent(), r.RemoteAddr
+	}
+	if err := conf.SessionStore.Save(session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// discardSession deletes the session identified by ID that recordSession
+// saved for the login that failed with err, so that the failed login doesn't
+// leave the session. err is returned with the error of the deletion if any.
+func discardSession(conf *config.Config, ID string, err error) error {
+	if ID == "" {
+		return err
+	}
+	if deleteErr := conf.SessionStore.Delete(ID); deleteErr != nil {
+		return errors.Join(err, deleteErr)
+	}
+	return err
+}
+
+// startSession creates a new session of user on Config.SessionStore, and
+// sets its ID to the session field.
+func startSession(
+	w http.ResponseWriter,
+	r *http.Request,
+	conf *config.Config, user models.IUser,
+) error {
+	if conf.SessionStore == nil {
+		return ErrNoSessionStore
+	}
+	now := conf.Now()
+	session, err := recordSession(r, conf, &store.Session{
+		UserID: user.GetID(), CreatedAt: now,
+		ExpireAt: expireAt(now, now, conf.ExpireIn, conf),
+	})
+	if err != nil {
+		return err
+	}
+	setToken(
+		w, conf, conf.SessionName, []byte(session.ID), session.ExpireAt.Sub(now),
+	)
+	conf.Emit(audit.Event{
+		Type: audit.Login, UserID: session.UserID, SessionID: session.ID,
+	})
+	return nil
+}
+
+// ExtractSession looks up the session identified by ID on
+// Config.SessionStore. store.ErrSessionNotFound is returned if the session
+// doesn't exist, and ErrExpired or ErrInvalidTenant is returned as
+// ValidationError if the session is expired or belongs to another tenant.
+func ExtractSession(ID string, conf *config.Config) (*store.Session, error) {
+	session, err := extractSession(ID, conf)
+	if err != nil {
+		emitFailure(conf, "", err)
+		return nil, err
+	}
+	return session, nil
+}
+
+func extractSession(ID string, conf *config.Config) (*store.Session, error) {
+	if conf.SessionStore == nil {
+		return nil, ErrNoSessionStore
+	}
+	session, err := conf.SessionStore.Get(ID)
+	if err != nil {
+		return nil, err
+	}
+	if conf.Tenant != "" && session.Tenant != conf.Tenant {
+		return nil, &ValidationError{
+			Claim: "tid", Expected: conf.Tenant, Actual: session.Tenant,
+			Err: ErrInvalidTenant,
+		}
+	}
+	if now := conf.Now(); !now.Before(session.ExpireAt) {
+		return nil, &ValidationError{
+			Claim: "exp", Expected: session.ExpireAt, Actual: now,
+			Err: ErrExpired,
+		}
+	}
+	return session, nil
+}
+
+// RenewSession extends the expiration of session according to
+// Config.RenewalPolicy like Renew, and saves it to Config.SessionStore.
+// The session never lives longer than Config.MaxSessionAge since it was
+// created.
+func RenewSession(
+	w http.ResponseWriter,
+	conf *config.Config,
+	session *store.Session,
+) (bool, error) {
+	now := conf.Now()
+	if !needsRenewal(session.ExpireAt.Sub(now), conf) {
+		return false, nil
+	}
+	exp := expireAt(now, session.CreatedAt, conf.ExpireIn, conf)
+	if conf.MaxSessionAge > 0 && !exp.After(session.ExpireAt) {
+		return false, nil
+	}
+	if conf.SessionStore == nil {
+		return false, ErrNoSessionStore
+	}
+	session.ExpireAt = exp
+	if err := conf.SessionStore.Save(session); err != nil {
+		return false, err
+	}
+	setToken(w, conf, conf.SessionName, []byte(session.ID), exp.Sub(now))
+	conf.Emit(audit.Event{
+		Type: audit.Renew, UserID: session.UserID, SessionID: session.ID,
+	})
+	return true, nil
+}
+
+// endSession deletes the session of the request from Config.SessionStore,
+// and revokes the refresh token family of the session. The ID of the user
+// of the session is returned.
+func endSession(ID string, conf *config.Config) (string, error) {
+	if conf.SessionStore == nil {
+		return "", nil
+	}
+	session, err := conf.SessionStore.Get(ID)
+	if errors.Is(err, store.ErrSessionNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return session.UserID, deleteSession(conf, session)
+}
+
+// deleteSession deletes session from Config.SessionStore, and revokes its
+// refresh token family so that the refresh tokens of the session can't
+// mint the access tokens anymore.
+func deleteSession(conf *config.Config, session *store.Session) error {
+	if session.Family != "" && conf.RefreshStore != nil {
+		if err := conf.RefreshStore.RevokeFamily(session.Family); err != nil {
+			return err
+		}
+	}
+	return conf.SessionStore.Delete(session.ID)
+}
+
+// extendSession extends the expiration of the session identified by ID to
+// exp. It is called when the token of the session is renewed. The session
+// is never shortened, because it may outlive the access token as the
+// session of the refresh token.
+func extendSession(ID string, exp time.Time, conf *config.Config) error {
+	session, err := conf.SessionStore.Get(ID)
+	if err != nil {
+		return err
+	}
+	if !exp.After(session.ExpireAt) {
+		return nil
+	}
+	session.ExpireAt = exp
+	return conf.SessionStore.Save(session)
+}
+
+// ListSessions returns the active sessions of the user identified by userID
+// in the order of the login. The sessions are recorded by Login and its
+// variants when Config.SessionStore is set, so the users can see where they
+// are logged in.
+func ListSessions(
+	conf *config.Config,
+	userID string,
+) ([]*store.Session, error) {
+	if conf.SessionStore == nil {
+		return nil, ErrNoSessionStore
+	}
+	return conf.SessionStore.List(userID)
+}
+
+// RevokeSession revokes the session identified by ID of the user identified
+// by userID. The session can't be used anymore, and the tokens of the
+// session are rejected by ExtractToken and its variants. The refresh token
+// family of the session is revoked as well.
+// store.ErrSessionNotFound is returned if the session doesn't belong to the
+// user, so that a user can't revoke the sessions of the others.
+func RevokeSession(conf *config.Config, userID, ID string) error {
+	if conf.SessionStore == nil {
+		return ErrNoSessionStore
+	}
+	session, err := conf.SessionStore.Get(ID)
+	if err != nil {
+		return err
+	}
+	if session.UserID != userID {
+		return store.ErrSessionNotFound
+	}
+	return revokeSession(conf, session)
+}
+
+// RevokeOtherSessions revokes all the sessions of the user identified by
+// userID except the one identified by currentID, i.e. signs out the other
+// devices.
+func RevokeOtherSessions(conf *config.Config, userID, currentID string) error {
+	sessions, err := ListSessions(conf, userID)
+	if err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		if session.ID == currentID {
+			continue
+		}
+		if err = revokeSession(conf, session); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RevokeAllSessions revokes all the sessions of the user identified by
+// userID, i.e. logs the user out everywhere.
+func RevokeAllSessions(conf *config.Config, userID string) error {
+	return RevokeOtherSessions(conf, userID, "")
+}
+
+func revokeSession(conf *config.Config, session *store.Session) error {
+	if err := deleteSession(conf, session); err != nil {
+		return err
+	}
+	conf.Emit(audit.Event{
+		Type: audit.Revoke, UserID: session.UserID, SessionID: session.ID,
+	})
+	return nil
+}
@@ -0,0 +1,332 @@
+package core_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hiroaki-yamamoto/gauth/audit"
+	"github.com/hiroaki-yamamoto/gauth/clock"
+	"github.com/hiroaki-yamamoto/gauth/config"
+	"github.com/hiroaki-yamamoto/gauth/core"
+	"github.com/hiroaki-yamamoto/gauth/store"
+	"gotest.tools/v3/assert"
+)
+
+// Server-side session test
+
+func sessionCookie(t *testing.T, rec *httptest.ResponseRecorder) *http.Cookie {
+	cookies := rec.Result().Cookies()
+	assert.Equal(t, len(cookies), 1)
+	return cookies[0]
+}
+
+func TestSession(t *testing.T) {
+	now := time.Unix(time.Now().Unix(), 0).UTC()
+	user := User{Username: "test_username"}
+
+	t.Run("Login", func(t *testing.T) {
+		fake := clock.NewFake(now)
+		conf, err := config.New(
+			"session", config.Session, mustHS256("test"),
+			"Test Audience", "Test Issuer", "Test Subject",
+			time.Hour, config.CookieConfig{Path: "/"},
+		)
+		assert.NilError(t, err)
+		sessionStore := store.NewMemorySessionStore()
+		sessionStore.Clock = fake
+		conf.Clock, conf.SessionStore = fake, sessionStore
+		var events []audit.Event
+		conf.Hook = audit.HookFunc(func(event audit.Event) error {
+			events = append(events, event)
+			return nil
+		})
+		rec := httptest.NewRecorder()
+		assert.NilError(t, core.Login(rec, conf, user))
+		cookie := sessionCookie(t, rec)
+		assert.Equal(t, cookie.MaxAge, 3600)
+
+		session, err := core.ExtractSession(cookie.Value, conf)
+		assert.NilError(t, err)
+		assert.Equal(t, session.UserID, "test_username")
+		assert.Equal(t, session.ExpireAt, now.Add(time.Hour))
+		assert.Equal(t, len(events), 1)
+		assert.Equal(t, events[0].Type, audit.Login)
+		assert.Equal(t, events[0].SessionID, cookie.Value)
+	})
+	t.Run("No store", func(t *testing.T) {
+		conf, err := config.New(
+			"session", config.Session, mustHS256("test"),
+			"Test Audience", "Test Issuer", "Test Subject",
+			time.Hour, config.CookieConfig{Path: "/"},
+		)
+		assert.NilError(t, err)
+		err = core.Login(httptest.NewRecorder(), conf, user)
+		assert.ErrorIs(t, err, core.ErrNoSessionStore)
+	})
+	t.Run("Unknown", func(t *testing.T) {
+		conf, err := config.New(
+			"session", config.Session, mustHS256("test"),
+			"Test Audience", "Test Issuer", "Test Subject",
+			time.Hour, config.CookieConfig{Path: "/"},
+		)
+		assert.NilError(t, err)
+		conf.SessionStore = store.NewMemorySessionStore()
+		_, err = core.ExtractSession("unknown", conf)
+		assert.ErrorIs(t, err, store.ErrSessionNotFound)
+	})
+	t.Run("Other tenant", func(t *testing.T) {
+		fake := clock.NewFake(now)
+		conf, err := config.New(
+			"session", config.Session, mustHS256("test"),
+			"Test Audience", "Test Issuer", "Test Subject",
+			time.Hour, config.CookieConfig{Path: "/"},
+		)
+		assert.NilError(t, err)
+		sessionStore := store.NewMemorySessionStore()
+		sessionStore.Clock = fake
+		conf.Clock, conf.SessionStore = fake, sessionStore
+		conf.Tenant = "tenant-a"
+		rec := httptest.NewRecorder()
+		assert.NilError(t, core.Login(rec, conf, user))
+		ID := sessionCookie(t, rec).Value
+		session, err := core.ExtractSession(ID, conf)
+		assert.NilError(t, err)
+		assert.Equal(t, session.Tenant, "tenant-a")
+
+		other := *conf
+		other.Tenant = "tenant-b"
+		_, err = core.ExtractSession(ID, &other)
+		assert.ErrorIs(t, err, core.ErrInvalidTenant)
+	})
+	t.Run("Expired", func(t *testing.T) {
+		fake := clock.NewFake(now)
+		conf, err := config.New(
+			"session", config.Session, mustHS256("test"),
+			"Test Audience", "Test Issuer", "Test Subject",
+			time.Hour, config.CookieConfig{Path: "/"},
+		)
+		assert.NilError(t, err)
+		sessionStore := store.NewMemorySessionStore()
+		sessionStore.Clock = fake
+		conf.Clock, conf.SessionStore = fake, sessionStore
+		rec := httptest.NewRecorder()
+		assert.NilError(t, core.Login(rec, conf, user))
+		fake.Advance(time.Hour)
+		_, err = core.ExtractSession(sessionCookie(t, rec).Value, conf)
+		assert.ErrorIs(t, err, store.ErrSessionNotFound)
+	})
+	t.Run("Renew", func(t *testing.T) {
+		fake := clock.NewFake(now)
+		conf, err := config.New(
+			"session", config.Session, mustHS256("test"),
+			"Test Audience", "Test Issuer", "Test Subject",
+			time.Hour, config.CookieConfig{Path: "/"},
+		)
+		assert.NilError(t, err)
+		sessionStore := store.NewMemorySessionStore()
+		sessionStore.Clock = fake
+		conf.Clock, conf.SessionStore = fake, sessionStore
+		conf.MaxSessionAge = 90 * time.Minute
+		rec := httptest.NewRecorder()
+		assert.NilError(t, core.Login(rec, conf, user))
+		ID := sessionCookie(t, rec).Value
+
+		fake.Advance(time.Minute)
+		session, err := core.ExtractSession(ID, conf)
+		assert.NilError(t, err)
+		rec = httptest.NewRecorder()
+		renewed, err := core.RenewSession(rec, conf, session)
+		assert.NilError(t, err)
+		assert.Assert(t, renewed)
+		assert.Equal(t, sessionCookie(t, rec).Value, ID)
+		session, err = core.ExtractSession(ID, conf)
+		assert.NilError(t, err)
+		assert.Equal(t, session.ExpireAt, now.Add(61*time.Minute))
+
+		fake.Advance(59 * time.Minute)
+		session, err = core.ExtractSession(ID, conf)
+		assert.NilError(t, err)
+		renewed, err = core.RenewSession(httptest.NewRecorder(), conf, session)
+		assert.NilError(t, err)
+		assert.Assert(t, renewed)
+		assert.Equal(t, session.ExpireAt, now.Add(90*time.Minute))
+
+		renewed, err = core.RenewSession(httptest.NewRecorder(), conf, session)
+		assert.NilError(t, err)
+		assert.Assert(t, !renewed)
+	})
+	t.Run("Logout", func(t *testing.T) {
+		conf, err := config.New(
+			"session", config.Session, mustHS256("test"),
+			"Test Audience", "Test Issuer", "Test Subject",
+			time.Hour, config.CookieConfig{Path: "/"},
+		)
+		assert.NilError(t, err)
+		conf.SessionStore = store.NewMemorySessionStore()
+		rec := httptest.NewRecorder()
+		assert.NilError(t, core.Login(rec, conf, user))
+		ID := sessionCookie(t, rec).Value
+		var events []audit.Event
+		conf.Hook = audit.HookFunc(func(event audit.Event) error {
+			events = append(events, event)
+			return nil
+		})
+
+		req := httptest.NewRequest("POST", "/logout", nil)
+		req.AddCookie(&http.Cookie{Name: conf.SessionName, Value: ID})
+		rec = httptest.NewRecorder()
+		assert.NilError(t, core.Logout(rec, req, conf))
+		assert.Equal(t, sessionCookie(t, rec).MaxAge, -1)
+		_, err = core.ExtractSession(ID, conf)
+		assert.ErrorIs(t, err, store.ErrSessionNotFound)
+		assert.Equal(t, events[0].Type, audit.Logout)
+		assert.Equal(t, events[0].UserID, "test_username")
+		assert.Equal(t, events[0].SessionID, ID)
+	})
+}
+
+func TestListSessions(t *testing.T) {
+	fake := clock.NewFake(time.Unix(time.Now().Unix(), 0).UTC())
+	conf, err := config.New(
+		"session", config.Header, mustHS256("test"),
+		"Test Audience", "Test Issuer", "Test Subject",
+		time.Hour, config.CookieConfig{},
+	)
+	assert.NilError(t, err)
+	sessionStore := store.NewMemorySessionStore()
+	sessionStore.Clock = fake
+	conf.Clock, conf.SessionStore = fake, sessionStore
+	user := User{Username: "test_username"}
+	login := func(agent string) string {
+		req := httptest.NewRequest("POST", "/login", nil)
+		req.Header.Set("User-Agent", agent)
+		rec := httptest.NewRecorder()
+		assert.NilError(t, core.LoginRequest(rec, req, conf, user))
+		fake.Advance(time.Second)
+		return rec.Header().Get("X-" + conf.SessionName)
+	}
+	phone, laptop, tablet := login("phone"), login("laptop"), login("tablet")
+
+	sessions, err := core.ListSessions(conf, "test_username")
+	assert.NilError(t, err)
+	assert.Equal(t, len(sessions), 3)
+	assert.Equal(t, sessions[0].UserAgent, "phone")
+	assert.Equal(t, sessions[0].RemoteAddr, "192.0.2.1:1234")
+	jot, err := core.ExtractAccess(phone, conf)
+	assert.NilError(t, err)
+	assert.Equal(t, jot.Claims.Custom.SessionID, sessions[0].ID)
+
+	t.Run("Other user", func(t *testing.T) {
+		err := core.RevokeSession(conf, "other", sessions[0].ID)
+		assert.ErrorIs(t, err, store.ErrSessionNotFound)
+	})
+	t.Run("Revoke", func(t *testing.T) {
+		err := core.RevokeSession(conf, "test_username", sessions[0].ID)
+		assert.NilError(t, err)
+		_, err = core.ExtractAccess(phone, conf)
+		assert.ErrorIs(t, err, core.ErrRevoked)
+		_, err = core.ExtractAccess(laptop, conf)
+		assert.NilError(t, err)
+	})
+	t.Run("Renew", func(t *testing.T) {
+		jot, err := core.ExtractClaims[core.CustomClaims[renewClaims]](laptop, conf)
+		assert.NilError(t, err)
+		rec := httptest.NewRecorder()
+		_, err = core.Renew(rec, conf, user, jot)
+		assert.NilError(t, err)
+		renewed := renewedToken(t, rec, conf)
+		assert.Equal(t, renewed.Claims.Custom.SessionID, sessions[1].ID)
+		session, err := conf.SessionStore.Get(sessions[1].ID)
+		assert.NilError(t, err)
+		assert.Assert(t, session.ExpireAt.Equal(renewed.Claims.Expiration.Time()))
+	})
+	t.Run("Others", func(t *testing.T) {
+		err := core.RevokeOtherSessions(conf, "test_username", sessions[2].ID)
+		assert.NilError(t, err)
+		_, err = core.ExtractAccess(laptop, conf)
+		assert.ErrorIs(t, err, core.ErrRevoked)
+		_, err = core.ExtractAccess(tablet, conf)
+		assert.NilError(t, err)
+	})
+	t.Run("All", func(t *testing.T) {
+		assert.NilError(t, core.RevokeAllSessions(conf, "test_username"))
+		sessions, err := core.ListSessions(conf, "test_username")
+		assert.NilError(t, err)
+		assert.Equal(t, len(sessions), 0)
+		_, err = core.ExtractAccess(tablet, conf)
+		assert.ErrorIs(t, err, core.ErrRevoked)
+	})
+}
+
+func TestRefreshSessions(t *testing.T) {
+	fake := clock.NewFake(time.Unix(time.Now().Unix(), 0).UTC())
+	conf, err := config.New(
+		"session", config.Header, mustHS256("test"),
+		"Test Audience", "Test Issuer", "Test Subject",
+		time.Hour, config.CookieConfig{},
+	)
+	assert.NilError(t, err)
+	sessionStore := store.NewMemorySessionStore()
+	sessionStore.Clock = fake
+	conf.Clock, conf.SessionStore = fake, sessionStore
+	conf.RefreshStore = store.NewMemoryRefreshStore()
+	now := fake.Now()
+	first, err := core.ComposePair("test_username", conf)
+	assert.NilError(t, err)
+	fake.Advance(time.Second)
+	second, err := core.ComposePair("test_username", conf)
+	assert.NilError(t, err)
+
+	sessions, err := core.ListSessions(conf, "test_username")
+	assert.NilError(t, err)
+	assert.Equal(t, len(sessions), 2)
+	assert.Assert(t, sessions[0].ExpireAt.Equal(
+		now.Add(conf.RefreshLifetime()),
+	))
+	refresh, err := core.ExtractRefresh(string(first.Refresh), conf)
+	assert.NilError(t, err)
+	assert.Equal(t, refresh.Claims.Custom.SessionID, sessions[0].ID)
+
+	fake.Advance(time.Minute)
+	rotated, err := core.RotatePair(refresh, conf)
+	assert.NilError(t, err)
+	access, err := core.ExtractAccess(string(rotated.Access), conf)
+	assert.NilError(t, err)
+	assert.Equal(t, access.Claims.Custom.SessionID, sessions[0].ID)
+
+	refresh, err = core.ExtractRefresh(string(second.Refresh), conf)
+	assert.NilError(t, err)
+	assert.NilError(t, core.RevokeAllSessions(conf, "test_username"))
+	_, err = core.RotatePair(refresh, conf)
+	assert.ErrorIs(t, err, core.ErrRevoked)
+	_, err = core.ExtractRefresh(string(rotated.Refresh), conf)
+	assert.ErrorIs(t, err, core.ErrRevoked)
+
+	family := refresh.Claims.Custom.Family
+	err = conf.RefreshStore.Rotate(
+		family, refresh.Claims.JWTID, "new", fake.Now().Add(time.Hour),
+	)
+	assert.ErrorIs(t, err, store.ErrUnknownFamily)
+}
+
+func TestFailedLoginSessions(t *testing.T) {
+	conf, err := config.New(
+		"session", config.Header, nil,
+		"Test Audience", "Test Issuer", "Test Subject",
+		time.Hour, config.CookieConfig{},
+	)
+	assert.NilError(t, err)
+	conf.SessionStore = store.NewMemorySessionStore()
+	conf.RefreshStore = store.NewMemoryRefreshStore()
+	user := User{Username: "test_username"}
+
+	err = core.Login(httptest.NewRecorder(), conf, user)
+	assert.ErrorIs(t, err, core.ErrNoSigner)
+	_, err = core.ComposePair("test_username", conf)
+	assert.ErrorIs(t, err, core.ErrNoSigner)
+	sessions, err := core.ListSessions(conf, "test_username")
+	assert.NilError(t, err)
+	assert.Equal(t, len(sessions), 0)
+}
@@ -0,0 +1,55 @@
+package core
+
+// Multi-tenant configuration
+
+import (
+	"net/http"
+
+	"codeberg.org/gbrlsnchs/jwt"
+	"github.com/hiroaki-yamamoto/gauth/config"
+)
+
+// ClaimResolver is a config.ConfigResolver that resolves the Config by
+// "tid" claim of the token in the request. The claim is read without the
+// verification only to select the Config, and the token is verified with
+// the selected one afterwards, so a forged claim doesn't pass. The opaque
+// session IDs of Session mode don't have the claim, so use the other
+// resolvers in Session mode.
+type ClaimResolver struct {
+	// MiddlewareType and SessionName locate the token in the request like
+	// the ones of Config.
+	MiddlewareType config.MiddlewareType
+	SessionName    string
+	// Configs maps the tenant ID to the Config whose Tenant is the ID.
+	Configs map[string]*config.Config
+	// Default is used for the requests without the token, e.g. the login
+	// requests. If this is nil, ErrNoToken is returned for them.
+	Default *config.Config
+}
+
+// Resolve implements config.ConfigResolver.
+func (me *ClaimResolver) Resolve(r *http.Request) (*config.Config, error) {
+	locator := &config.Config{
+		MiddlewareType: me.MiddlewareType, SessionName: me.SessionName,
+	}
+	token := tokenFromRequest(r, locator, me.SessionName)
+	if token == "" && me.Default != nil {
+		return me.Default, nil
+	}
+	if token == "" {
+		return nil, ErrNoToken
+	}
+	t, err := jwt.Parse([]byte(token))
+	if err != nil {
+		return nil, wrapError(ErrMalformedToken, err)
+	}
+	jot, err := jwt.Decode[Claims](t)
+	if err != nil {
+		return nil, wrapError(ErrMalformedToken, err)
+	}
+	conf, ok := me.Configs[jot.Claims.Custom.Tenant]
+	if !ok {
+		return nil, config.ErrUnknownTenant
+	}
+	return conf, nil
+}
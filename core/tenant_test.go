@@ -0,0 +1,81 @@
+package core_test
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hiroaki-yamamoto/gauth/config"
+	"github.com/hiroaki-yamamoto/gauth/core"
+	"github.com/hiroaki-yamamoto/gauth/store"
+	"gotest.tools/v3/assert"
+)
+
+// Multi-tenant test
+
+func TestTenant(t *testing.T) {
+	acme, err := config.New(
+		"session", config.Header, mustHS256("test"),
+		"Test Audience", "Test Issuer", "Test Subject",
+		time.Hour, config.CookieConfig{},
+	)
+	assert.NilError(t, err)
+	acme.RefreshStore = store.NewMemoryRefreshStore()
+	acme.Tenant = "acme"
+	initech, err := config.New(
+		"session", config.Header, mustHS256("test"),
+		"Test Audience", "Test Issuer", "Test Subject",
+		time.Hour, config.CookieConfig{},
+	)
+	assert.NilError(t, err)
+	initech.Tenant = "initech"
+	token, err := core.ComposeID("test_username", acme)
+	assert.NilError(t, err)
+
+	t.Run("Own tenant", func(t *testing.T) {
+		jot, err := core.ExtractAccess(string(token), acme)
+		assert.NilError(t, err)
+		assert.Equal(t, jot.Claims.Custom.Tenant, "acme")
+	})
+	t.Run("Another tenant", func(t *testing.T) {
+		_, err := core.ExtractAccess(string(token), initech)
+		assert.ErrorIs(t, err, core.ErrInvalidTenant)
+		pair, err := core.ComposePair("test_username", acme)
+		assert.NilError(t, err)
+		_, err = core.ExtractRefresh(string(pair.Refresh), initech)
+		assert.ErrorIs(t, err, core.ErrInvalidTenant)
+	})
+	t.Run("Resolver", func(t *testing.T) {
+		resolver := &core.ClaimResolver{
+			MiddlewareType: config.Header, SessionName: "session",
+			Configs: map[string]*config.Config{
+				"acme": acme, "initech": initech,
+			},
+		}
+		req := httptest.NewRequest("GET", "/", nil)
+		_, err := resolver.Resolve(req)
+		assert.ErrorIs(t, err, core.ErrNoToken)
+		resolver.Default = initech
+		conf, err := resolver.Resolve(req)
+		assert.NilError(t, err)
+		assert.Equal(t, conf, initech)
+
+		req.Header.Set("session", string(token))
+		conf, err = resolver.Resolve(req)
+		assert.NilError(t, err)
+		assert.Equal(t, conf, acme)
+
+		unknown, err := config.New(
+			"session", config.Header, mustHS256("test"),
+			"Test Audience", "Test Issuer", "Test Subject",
+			time.Hour, config.CookieConfig{},
+		)
+		assert.NilError(t, err)
+		unknown.Tenant = "unknown"
+		token, err := core.ComposeID("test_username", unknown)
+		assert.NilError(t, err)
+		req.Header.Set("session", string(token))
+		_, err = resolver.Resolve(req)
+		assert.ErrorIs(t, err, config.ErrUnknownTenant)
+	})
+}
@@ -6,77 +6,200 @@ import (
 
 	"codeberg.org/gbrlsnchs/jwt"
 	"github.com/hiroaki-yamamoto/gauth/config"
+	"github.com/hiroaki-yamamoto/gauth/models"
+	"github.com/hiroaki-yamamoto/gauth/store"
 )
 
 // ComposeToken generates JWT token string from specified paramenters.
+// If signer is a KeyedSigner, "kid" header is stamped.
 func ComposeToken(model *jwt.JWT[jwt.None], signer jwt.Signer) ([]byte, error) {
-	return jwt.Sign(model, signer)
+	return sign(model, signer)
 }
 
 // ComposeID generates JWT token string with specified ID
 // (that is generally used as an username) and Config.
+// The ID is stored in "uid" claim, and "jti" claim is a random ID
+// that identifies the token itself.
 func ComposeID(ID string, config *config.Config) ([]byte, error) {
-	now := time.Now()
+	return ComposeClaims(ID, jwt.None{}, config)
+}
+
+// ComposeUser is the same as ComposeID, but it takes the user, and also
+// embeds the token version if user implements models.TokenVersioner.
+func ComposeUser(user models.IUser, config *config.Config) ([]byte, error) {
+	now := config.Now()
+	_, _, token, err := composeClaims(
+		claimsOf(user), jwt.None{}, now, now, config,
+	)
+	return token, err
+}
+
+// ComposeClaims is the same as ComposeID, but it also embeds custom as
+// private claims. T must be a struct or a map, and its claims must not
+// collide with the registered claims nor the ones of Claims.
+func ComposeClaims[T any](
+	ID string,
+	custom T,
+	config *config.Config,
+) ([]byte, error) {
+	now := config.Now()
+	_, _, token, err := composeClaims(
+		Claims{UserID: ID}, custom, now, now, config,
+	)
+	return token, err
+}
+
+// composeClaims composes the token with claims of the session that started
+// at authTime, and returns it with its ID and expiration time.
+func composeClaims[T any](
+	claims Claims,
+	custom T,
+	now, authTime time.Time,
+	config *config.Config,
+) (string, time.Time, []byte, error) {
+	exp := expireAt(now, authTime, config.ExpireIn, config)
+	jti := newTokenID()
+	claims.AuthTime = jwt.ConvertTime(authTime)
+	claims.Tenant = config.Tenant
 	var aud jwt.Audience
 	if config.Audience != "" {
 		aud = jwt.Audience{config.Audience}
 	}
-	jot := &jwt.JWT[jwt.None]{
-		Claims: jwt.Claims[jwt.None]{
+	jot := &jwt.JWT[CustomClaims[T]]{
+		Claims: jwt.Claims[CustomClaims[T]]{
 			Issuer:     config.Issuer,
 			Subject:    config.Subject,
 			Audience:   aud,
-			Expiration: jwt.ConvertTime(now.Add(config.ExpireIn)),
+			Expiration: jwt.ConvertTime(exp),
 			NotBefore:  jwt.ConvertTime(now),
 			IssuedAt:   jwt.ConvertTime(now),
-			JWTID:      ID,
+			JWTID:      jti,
+			Custom:     CustomClaims[T]{Claims: claims, Custom: custom},
 		},
 	}
-	return ComposeToken(jot, config.Signer)
+	token, err := sign(jot, config.Signer)
+	return jti, exp, token, err
 }
 
 // ExtractToken extracts token string into verified JWT object.
+// If Config.Signer is a KeyedVerifier, the token is verified with the key
+// selected by "kid" header.
 func ExtractToken(
 	token string,
 	config *config.Config,
 ) (*jwt.JWT[jwt.None], error) {
-	now := time.Now().UTC()
-	t, err := jwt.Parse([]byte(token))
+	return ExtractClaims[jwt.None](token, config)
+}
+
+// ExtractAccess is the same as ExtractToken, but it also decodes the private
+// claims that gauth embeds.
+func ExtractAccess(
+	token string,
+	config *config.Config,
+) (*jwt.JWT[Claims], error) {
+	return ExtractClaims[Claims](token, config)
+}
+
+// ExtractClaims is the same as ExtractToken, but it decodes the private
+// claims into T. Use CustomClaims[T] as T to decode both the custom claims
+// and the ones that gauth embeds.
+func ExtractClaims[T any](
+	token string,
+	config *config.Config,
+) (*jwt.JWT[T], error) {
+	jot, err := extract[T](token, config)
+	if err == nil && jot.Header.Type == refreshTokenType {
+		err = &ValidationError{
+			Claim: "typ", Expected: "", Actual: jot.Header.Type,
+			JWTID: jot.Claims.JWTID, Err: ErrRefreshAsAccess,
+		}
+	}
 	if err != nil {
+		emitFailure(config, "", err)
 		return nil, err
 	}
+	return jot, nil
+}
 
-	verifier, ok := config.Signer.(jwt.Verifier)
-	if !ok {
-		return nil, errors.New("Signer does not implement jwt.Verifier")
+func extract[T any](
+	token string,
+	config *config.Config,
+) (*jwt.JWT[T], error) {
+	now := config.Now()
+	t, err := jwt.Parse([]byte(token))
+	if err != nil {
+		return nil, wrapError(ErrMalformedToken, err)
 	}
 
-	if err = jwt.Verify(t, verifier); err != nil {
+	verifier, err := verifierFor(token, config)
+	if err != nil {
 		return nil, err
 	}
 
-	jot, err := jwt.Decode[jwt.None](t)
+	if err = jwt.Verify(t, verifier); err != nil {
+		return nil, wrapError(ErrInvalidSignature, err)
+	}
+
+	jot, err := jwt.Decode[T](t)
 	if err != nil {
-		return nil, err
+		return nil, wrapError(ErrMalformedToken, err)
 	}
 
-	if jot.IsExpired(now) {
-		return nil, errors.New("jwt is expired")
+	claims := &jot.Claims
+	invalid := func(claim string, expected, actual any, err error) error {
+		return &ValidationError{
+			Claim: claim, Expected: expected, Actual: actual,
+			JWTID: claims.JWTID, Err: err,
+		}
+	}
+	if jot.IsExpired(now.Add(-config.Leeway)) {
+		return nil, invalid("exp", claims.Expiration.Time(), now, ErrExpired)
 	}
-	if !jot.IsActive(now) {
-		return nil, errors.New("jwt is not active yet")
+	if !jot.IsActive(now.Add(config.Leeway)) {
+		return nil, invalid("nbf", claims.NotBefore.Time(), now, ErrNotActive)
 	}
-	if time.Unix(int64(jot.Claims.IssuedAt), 0).After(now) {
-		return nil, errors.New("jwt used before issued")
+	if claims.IssuedAt.Time().After(now.Add(config.Leeway)) {
+		return nil, invalid("iat", claims.IssuedAt.Time(), now, ErrIssuedInFuture)
 	}
 	if config.Audience != "" && !jot.InScope(config.Audience) {
-		return nil, errors.New("invalid audience")
+		return nil, invalid(
+			"aud", config.Audience, claims.Audience, ErrInvalidAudience,
+		)
+	}
+	if config.Issuer != "" && claims.Issuer != config.Issuer {
+		return nil, invalid("iss", config.Issuer, claims.Issuer, ErrInvalidIssuer)
+	}
+	if config.Subject != "" && claims.Subject != config.Subject {
+		return nil, invalid(
+			"sub", config.Subject, claims.Subject, ErrInvalidSubject,
+		)
+	}
+	private, err := privateClaimsOf(t, config)
+	if err != nil {
+		return nil, err
+	}
+	if config.Tenant != "" && private.Tenant != config.Tenant {
+		return nil, invalid(
+			"tid", config.Tenant, private.Tenant, ErrInvalidTenant,
+		)
 	}
-	if config.Issuer != "" && jot.Claims.Issuer != config.Issuer {
-		return nil, errors.New("invalid issuer")
+	if sid := private.SessionID; config.SessionStore != nil && sid != "" {
+		_, err = config.SessionStore.Get(sid)
+		if errors.Is(err, store.ErrSessionNotFound) {
+			return nil, invalid("sid", nil, sid, ErrRevoked)
+		}
+		if err != nil {
+			return nil, err
+		}
 	}
-	if config.Subject != "" && jot.Claims.Subject != config.Subject {
-		return nil, errors.New("invalid subject")
+	if config.Revoker != nil && claims.JWTID != "" {
+		revoked, err := config.Revoker.IsRevoked(claims.JWTID)
+		if err != nil {
+			return nil, err
+		}
+		if revoked {
+			return nil, invalid("jti", nil, claims.JWTID, ErrRevoked)
+		}
 	}
 
 	return jot, nil
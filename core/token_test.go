@@ -8,6 +8,8 @@ import (
 
 	"codeberg.org/gbrlsnchs/jwt"
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/hiroaki-yamamoto/gauth/clock"
 	_conf "github.com/hiroaki-yamamoto/gauth/config"
 	"github.com/hiroaki-yamamoto/gauth/core"
 	"gotest.tools/v3/assert"
@@ -65,10 +67,15 @@ func TestNormalTokenFunc(t *testing.T) {
 		assert.NilError(t, err)
 		extractedToken, err := core.ExtractToken(string(composedToken), &config)
 		assert.NilError(t, err)
+		assert.Assert(t, extractedToken.Claims.JWTID != token.Claims.JWTID)
 		assert.DeepEqual(
 			t, *extractedToken, *token,
 			cmp.AllowUnexported(*extractedToken, *token),
+			cmpopts.IgnoreFields(jwt.Claims[jwt.None]{}, "JWTID"),
 		)
+		access, err := core.ExtractAccess(string(composedToken), &config)
+		assert.NilError(t, err)
+		assert.Equal(t, access.Claims.Custom.UserID, token.Claims.JWTID)
 	})
 }
 
@@ -206,6 +213,41 @@ func (dummySigner) Name() string                        { return "DUMMY" }
 func (dummySigner) Sign(payload []byte) ([]byte, error) { return []byte("dummy signature"), nil }
 func (dummySigner) Size() int                           { return 15 }
 
+func TestConfigClock(t *testing.T) {
+	fake := clock.NewFake(now)
+	conf := &_conf.Config{
+		Signer:   mustHS256("test secret key"),
+		ExpireIn: time.Hour,
+		Clock:    fake,
+	}
+	token, err := core.ComposeID("test_username", conf)
+	assert.NilError(t, err)
+	jot, err := core.ExtractAccess(string(token), conf)
+	assert.NilError(t, err)
+	assert.Equal(t, jot.Claims.IssuedAt, jwt.ConvertTime(now))
+
+	fake.Advance(time.Hour)
+	_, err = core.ExtractToken(string(token), conf)
+	assert.Error(t, err, "jwt is expired")
+
+	t.Run("Leeway", func(t *testing.T) {
+		conf := *conf
+		conf.Leeway = time.Minute
+		_, err := core.ExtractToken(string(token), &conf)
+		assert.NilError(t, err)
+		fake.Advance(time.Minute)
+		_, err = core.ExtractToken(string(token), &conf)
+		assert.Error(t, err, "jwt is expired")
+
+		fake.Set(now.Add(-30 * time.Second))
+		_, err = core.ExtractToken(string(token), &conf)
+		assert.NilError(t, err)
+		conf.Leeway = 0
+		_, err = core.ExtractToken(string(token), &conf)
+		assert.Error(t, err, "jwt is not active yet")
+	})
+}
+
 func TestExtractTokenNotVerifier(t *testing.T) {
 	signer := mustHS256("test secret key")
 	tok := GetFixture()
@@ -254,3 +296,36 @@ func TestDecodeFailure(t *testing.T) {
 	}
 	assert.Assert(t, extracted == nil, extracted)
 }
+
+type RoleClaims struct {
+	Role   string `json:"role,omitzero"`
+	Tenant string `json:"tenant,omitzero"`
+}
+
+func TestCustomClaims(t *testing.T) {
+	config := &_conf.Config{
+		Signer:   mustHS256("test secret key"),
+		Audience: "test audience",
+		ExpireIn: 2 * time.Hour,
+	}
+	custom := RoleClaims{Role: "admin", Tenant: "example"}
+	token, err := core.ComposeClaims("test username", custom, config)
+	assert.NilError(t, err)
+
+	t.Run("Custom claims only", func(t *testing.T) {
+		extracted, err := core.ExtractClaims[RoleClaims](string(token), config)
+		assert.NilError(t, err)
+		assert.Equal(t, extracted.Claims.Custom, custom)
+		assert.DeepEqual(
+			t, extracted.Claims.Audience, jwt.Audience{"test audience"},
+		)
+	})
+	t.Run("With gauth claims", func(t *testing.T) {
+		extracted, err := core.ExtractClaims[core.CustomClaims[RoleClaims]](
+			string(token), config,
+		)
+		assert.NilError(t, err)
+		assert.Equal(t, extracted.Claims.Custom.UserID, "test username")
+		assert.Equal(t, extracted.Claims.Custom.Custom, custom)
+	})
+}
@@ -0,0 +1,70 @@
+package core_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hiroaki-yamamoto/gauth/config"
+	"github.com/hiroaki-yamamoto/gauth/core"
+	"github.com/hiroaki-yamamoto/gauth/store"
+	"gotest.tools/v3/assert"
+)
+
+// Token version test
+
+type VersionedUser struct {
+	User
+	Version int64
+}
+
+func (me VersionedUser) GetTokenVersion() int64 {
+	return me.Version
+}
+
+func TestTokenVersion(t *testing.T) {
+	conf, err := config.New(
+		"session", config.Header, mustHS256("test"),
+		"Test Audience", "Test Issuer", "Test Subject",
+		time.Hour, config.CookieConfig{},
+	)
+	assert.NilError(t, err)
+	conf.RefreshStore = store.NewMemoryRefreshStore()
+	user := VersionedUser{User{Username: "test_username"}, 1}
+	token, err := core.ComposeUser(user, conf)
+	assert.NilError(t, err)
+	jot, err := core.ExtractAccess(string(token), conf)
+	assert.NilError(t, err)
+	assert.Equal(t, jot.Claims.Custom.TokenVersion, int64(1))
+
+	t.Run("Current", func(t *testing.T) {
+		err := core.CheckTokenVersion(conf, user, jot.Claims.Custom, "")
+		assert.NilError(t, err)
+	})
+	t.Run("Stale", func(t *testing.T) {
+		user := VersionedUser{user.User, 2}
+		err := core.CheckTokenVersion(
+			conf, user, jot.Claims.Custom, jot.Claims.JWTID,
+		)
+		assert.ErrorIs(t, err, core.ErrStaleToken)
+		verr := err.(*core.ValidationError)
+		assert.Equal(t, verr.Claim, "ver")
+		assert.Equal(t, verr.Expected, int64(2))
+		assert.Equal(t, verr.JWTID, jot.Claims.JWTID)
+	})
+	t.Run("Unversioned", func(t *testing.T) {
+		err := core.CheckTokenVersion(conf, user.User, core.Claims{}, "")
+		assert.NilError(t, err)
+	})
+	t.Run("Refresh", func(t *testing.T) {
+		pair, err := core.ComposeUserPair(user, conf)
+		assert.NilError(t, err)
+		refresh, err := core.ExtractRefresh(string(pair.Refresh), conf)
+		assert.NilError(t, err)
+		assert.Equal(t, refresh.Claims.Custom.TokenVersion, int64(1))
+		pair, err = core.RotatePair(refresh, conf)
+		assert.NilError(t, err)
+		jot, err := core.ExtractAccess(string(pair.Access), conf)
+		assert.NilError(t, err)
+		assert.Equal(t, jot.Claims.Custom.TokenVersion, int64(1))
+	})
+}
@@ -1,9 +1,15 @@
 module github.com/hiroaki-yamamoto/gauth
 
-go 1.25
+go 1.25.0
 
 require (
 	codeberg.org/gbrlsnchs/jwt v0.1.0
 	github.com/google/go-cmp v0.7.0
+	github.com/pelletier/go-toml/v2 v2.3.1
+	go.etcd.io/bbolt v1.4.3
+	golang.org/x/crypto v0.54.0
+	gopkg.in/yaml.v3 v3.0.1
 	gotest.tools/v3 v3.5.2
 )
+
+require golang.org/x/sys v0.47.0 // indirect
@@ -0,0 +1,105 @@
+package keyset
+
+// JSON Web Key Set
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"codeberg.org/gbrlsnchs/jwt"
+)
+
+// JWK is a public JSON Web Key according to RFC 7517. Only the parameters of
+// Ed25519 keys (RFC 8037) are supported because the public halves of the
+// other algorithms that gauth supports are secret.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid,omitempty"`
+	Use       string `json:"use,omitempty"`
+	Algorithm string `json:"alg,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+}
+
+// JWKSet is a JSON Web Key Set according to RFC 7517.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// publicKey returns the Ed25519 public key of the key, or nil if the key is
+// not an Ed25519 key.
+func (me Key) publicKey() ed25519.PublicKey {
+	if verifier, ok := me.verifier().(jwt.Ed25519Verifier); ok {
+		return ed25519.PublicKey(verifier)
+	}
+	return nil
+}
+
+// JWKS returns the public halves of the asymmetric keys that are not
+// expired. The keys that are not active yet are included so that the
+// verifiers learn them before the tokens signed by them appear.
+func (me *KeySet) JWKS() JWKSet {
+	now := me.Clock.Now()
+	me.mutex.RLock()
+	defer me.mutex.RUnlock()
+	set := JWKSet{Keys: []JWK{}}
+	for _, key := range me.keys {
+		if !key.ExpireAt.IsZero() && !now.Before(key.ExpireAt) {
+			continue
+		}
+		pub := key.publicKey()
+		if pub == nil {
+			continue
+		}
+		set.Keys = append(set.Keys, JWK{
+			KeyType:   "OKP",
+			KeyID:     key.ID,
+			Use:       "sig",
+			Algorithm: "EdDSA",
+			Curve:     "Ed25519",
+			X:         base64.RawURLEncoding.EncodeToString(pub),
+		})
+	}
+	return set
+}
+
+// JWKSHandler returns a handler that publishes ks.JWKS() as JSON.
+// The response may be cached by the clients for maxAge.
+func JWKSHandler(ks *KeySet, maxAge time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/jwk-set+json")
+		w.Header().Set(
+			"Cache-Control",
+			"public, max-age="+strconv.Itoa(int(maxAge/time.Second)),
+		)
+		json.NewEncoder(w).Encode(ks.JWKS())
+	})
+}
+
+// Verifier returns the verifier of the key.
+func (me JWK) Verifier() (jwt.Verifier, error) {
+	if me.KeyType != "OKP" || me.Curve != "Ed25519" {
+		return nil, fmt.Errorf(
+			"unsupported key type: kty=%q, crv=%q", me.KeyType, me.Curve,
+		)
+	}
+	x, err := base64.RawURLEncoding.DecodeString(me.X)
+	if err != nil {
+		return nil, err
+	}
+	if len(x) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid Ed25519 public key size")
+	}
+	return jwt.Ed25519Verifier(x), nil
+}
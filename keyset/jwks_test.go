@@ -0,0 +1,213 @@
+package keyset_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"codeberg.org/gbrlsnchs/jwt"
+	"github.com/hiroaki-yamamoto/gauth/config"
+	"github.com/hiroaki-yamamoto/gauth/core"
+	"github.com/hiroaki-yamamoto/gauth/keyset"
+	"gotest.tools/v3/assert"
+)
+
+// JWKS test
+
+func mustEd25519(t *testing.T) jwt.Ed25519Signer {
+	_, priv, err := ed25519.GenerateKey(nil)
+	assert.NilError(t, err)
+	return jwt.Ed25519Signer(priv)
+}
+
+func TestJWKSHandler(t *testing.T) {
+	now := time.Now().UTC()
+	signer := mustEd25519(t)
+	ks, err := keyset.New(
+		keyset.Key{ID: "ed", Signer: signer},
+		keyset.Key{ID: "hmac", Signer: mustHS256("secret")},
+		keyset.Key{ID: "expired", Signer: mustEd25519(t), ExpireAt: now},
+	)
+	assert.NilError(t, err)
+	ks.Clock = TimeMock{now}
+
+	rec := httptest.NewRecorder()
+	keyset.JWKSHandler(ks, time.Hour).ServeHTTP(
+		rec, httptest.NewRequest("GET", "/jwks.json", nil),
+	)
+	assert.Equal(t, rec.Code, http.StatusOK)
+	assert.Equal(t, rec.Header().Get("Content-Type"), "application/jwk-set+json")
+	assert.Equal(t, rec.Header().Get("Cache-Control"), "public, max-age=3600")
+	var set keyset.JWKSet
+	assert.NilError(t, json.NewDecoder(rec.Body).Decode(&set))
+	assert.Equal(t, len(set.Keys), 1)
+	assert.Equal(t, set.Keys[0].KeyID, "ed")
+
+	verifier, err := set.Keys[0].Verifier()
+	assert.NilError(t, err)
+	sig, err := signer.Sign([]byte("payload"))
+	assert.NilError(t, err)
+	assert.NilError(t, verifier.Verify([]byte("payload"), sig))
+
+	t.Run("Method not allowed", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		keyset.JWKSHandler(ks, time.Hour).ServeHTTP(
+			rec, httptest.NewRequest("POST", "/jwks.json", nil),
+		)
+		assert.Equal(t, rec.Code, http.StatusMethodNotAllowed)
+	})
+	t.Run("Unsupported key", func(t *testing.T) {
+		_, err := keyset.JWK{KeyType: "oct"}.Verifier()
+		assert.ErrorContains(t, err, "unsupported key type")
+	})
+}
+
+func TestRemote(t *testing.T) {
+	now := time.Now().UTC()
+	ks, err := keyset.New(keyset.Key{ID: "first", Signer: mustEd25519(t)})
+	assert.NilError(t, err)
+	var fetches atomic.Int32
+	var failing atomic.Bool
+	jwks := keyset.JWKSHandler(ks, time.Hour)
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			fetches.Add(1)
+			if failing.Load() {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			jwks.ServeHTTP(w, r)
+		},
+	))
+	defer srv.Close()
+
+	remote := keyset.NewRemote(srv.URL)
+	remote.Clock = TimeMock{now}
+	signConf := &config.Config{Signer: ks, ExpireIn: time.Hour}
+	verifyConf := &config.Config{Verifier: remote}
+
+	token, err := core.ComposeID("test_username", signConf)
+	assert.NilError(t, err)
+	_, err = core.ExtractToken(string(token), verifyConf)
+	assert.NilError(t, err)
+	assert.Equal(t, fetches.Load(), int32(1))
+
+	t.Run("Cached", func(t *testing.T) {
+		_, err = core.ExtractToken(string(token), verifyConf)
+		assert.NilError(t, err)
+		assert.Equal(t, fetches.Load(), int32(1))
+	})
+	t.Run("Refresh on unknown kid", func(t *testing.T) {
+		assert.NilError(t, ks.Add(keyset.Key{
+			ID: "second", Signer: mustEd25519(t), NotBefore: now,
+		}))
+		ks.Clock = TimeMock{now.Add(time.Second)}
+		token, err := core.ComposeID("test_username", signConf)
+		assert.NilError(t, err)
+
+		_, err = core.ExtractToken(string(token), verifyConf)
+		assert.ErrorIs(t, err, keyset.ErrUnknownKey)
+		assert.Equal(t, fetches.Load(), int32(1))
+
+		remote.Clock = TimeMock{now.Add(time.Minute)}
+		_, err = core.ExtractToken(string(token), verifyConf)
+		assert.NilError(t, err)
+		assert.Equal(t, fetches.Load(), int32(2))
+	})
+	t.Run("Backoff", func(t *testing.T) {
+		failing.Store(true)
+		remote.Clock = TimeMock{now.Add(2 * time.Minute)}
+		_, err := remote.VerifierFor("unknown")
+		assert.ErrorContains(t, err, "500")
+		assert.Equal(t, fetches.Load(), int32(3))
+
+		remote.Clock = TimeMock{now.Add(3 * time.Minute)}
+		_, err = remote.VerifierFor("unknown")
+		assert.ErrorIs(t, err, keyset.ErrUnknownKey)
+		assert.Equal(t, fetches.Load(), int32(4))
+
+		// The second failure doubles the interval.
+		remote.Clock = TimeMock{now.Add(4 * time.Minute)}
+		_, err = remote.VerifierFor("unknown")
+		assert.ErrorIs(t, err, keyset.ErrUnknownKey)
+		assert.Equal(t, fetches.Load(), int32(4))
+
+		// The stale keys are still used.
+		_, err = core.ExtractToken(string(token), verifyConf)
+		assert.NilError(t, err)
+	})
+	t.Run("Token without kid", func(t *testing.T) {
+		_, signer, err := ks.CurrentSigner()
+		assert.NilError(t, err)
+		token, err := jwt.Sign(&jwt.JWT[jwt.None]{
+			Claims: jwt.Claims[jwt.None]{
+				Expiration: jwt.ConvertTime(time.Now().Add(time.Hour)),
+			},
+		}, signer)
+		assert.NilError(t, err)
+		_, err = core.ExtractToken(string(token), verifyConf)
+		assert.NilError(t, err)
+	})
+}
+
+func TestRemoteFetch(t *testing.T) {
+	ks, err := keyset.New(keyset.Key{ID: "first", Signer: mustEd25519(t)})
+	assert.NilError(t, err)
+	jwks := keyset.JWKSHandler(ks, time.Hour)
+	var slow, oversized atomic.Bool
+	fetching, release := make(chan struct{}), make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case slow.Load():
+				fetching <- struct{}{}
+				<-release
+			case oversized.Load():
+				w.Write([]byte(`{"keys": [`))
+				w.Write(bytes.Repeat([]byte(" "), keyset.MaxRemoteSize))
+				w.Write([]byte(`]}`))
+				return
+			}
+			jwks.ServeHTTP(w, r)
+		},
+	))
+	defer srv.Close()
+	remote := keyset.NewRemote(srv.URL)
+	remote.MinInterval = time.Nanosecond
+	assert.NilError(t, remote.Refresh(context.Background()))
+
+	t.Run("Cached keys during a slow fetch", func(t *testing.T) {
+		slow.Store(true)
+		defer slow.Store(false)
+		done := make(chan error)
+		go func() {
+			_, err := remote.VerifierFor("unknown")
+			done <- err
+		}()
+		<-fetching
+		verified := make(chan error)
+		go func() {
+			_, err := remote.VerifierFor("first")
+			verified <- err
+		}()
+		select {
+		case err := <-verified:
+			assert.NilError(t, err)
+		case <-time.After(time.Second):
+			t.Error("the cached key waits for the fetch")
+		}
+		close(release)
+		assert.ErrorIs(t, <-done, keyset.ErrUnknownKey)
+	})
+	t.Run("Oversized set", func(t *testing.T) {
+		oversized.Store(true)
+		defer oversized.Store(false)
+		assert.Assert(t, remote.Refresh(context.Background()) != nil)
+	})
+}
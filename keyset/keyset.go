@@ -0,0 +1,234 @@
+// Package keyset provides KeySet, a set of signing keys that supports key
+// rotation.
+//
+// Each Key has an ID that is stamped to "kid" header of the tokens it signs,
+// and a schedule that describes when the key starts signing, when it stops
+// signing, and when it stops verifying. KeySet signs with the newest active
+// key and verifies with the key selected by "kid" header, so that the tokens
+// signed by a retiring key stay valid while a new key takes over.
+//
+// KeySet implements jwt.Signer and jwt.Verifier, so it can be set to
+// config.Config.Signer as-is:
+//
+//	ks, err := keyset.New(
+//		keyset.Key{ID: "2024", Signer: oldSigner, RetireAt: rotateAt, ExpireAt: rotateAt.Add(expireIn)},
+//		keyset.Key{ID: "2025", Signer: newSigner, NotBefore: rotateAt},
+//	)
+//	conf.Signer = ks
+package keyset
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"codeberg.org/gbrlsnchs/jwt"
+	"github.com/hiroaki-yamamoto/gauth/clock"
+)
+
+var (
+	// ErrNoActiveKey is returned when there's no key that can sign a token.
+	ErrNoActiveKey = errors.New("no active signing key")
+	// ErrUnknownKey is returned when there's no key that can verify a token
+	// with the specified key ID.
+	ErrUnknownKey = errors.New("unknown key id")
+)
+
+// Key is a signing / verification key with its schedule.
+type Key struct {
+	// ID is the key ID that is stamped to "kid" header.
+	ID string
+	// Signer signs the tokens. This can be nil for verification-only keys.
+	Signer jwt.Signer
+	// Verifier verifies the tokens. If this is nil, Signer is used when it
+	// implements jwt.Verifier, or the public key is derived from Signer when
+	// it is jwt.Ed25519Signer.
+	Verifier jwt.Verifier
+	// NotBefore is the time the key becomes active. Zero means the key is
+	// active from the beginning.
+	NotBefore time.Time
+	// RetireAt is the time the key stops signing. The key still verifies the
+	// tokens until ExpireAt. Zero means the key never retires.
+	RetireAt time.Time
+	// ExpireAt is the time the key stops verifying. This should be later
+	// than RetireAt by the lifetime of the tokens. Zero means the key never
+	// expires.
+	ExpireAt time.Time
+}
+
+func (me Key) verifier() jwt.Verifier {
+	if me.Verifier != nil {
+		return me.Verifier
+	}
+	switch signer := me.Signer.(type) {
+	case jwt.Verifier:
+		return signer
+	case jwt.Ed25519Signer:
+		pub := ed25519.PrivateKey(signer).Public().(ed25519.PublicKey)
+		return jwt.Ed25519Verifier(pub)
+	}
+	return nil
+}
+
+// IsActive returns true if the key can sign the tokens at now.
+func (me Key) IsActive(now time.Time) bool {
+	return me.Signer != nil && !now.Before(me.NotBefore) &&
+		(me.RetireAt.IsZero() || now.Before(me.RetireAt))
+}
+
+// CanVerify returns true if the key can verify the tokens at now, i.e. the
+// key is active or retiring.
+func (me Key) CanVerify(now time.Time) bool {
+	return me.verifier() != nil && !now.Before(me.NotBefore) &&
+		(me.ExpireAt.IsZero() || now.Before(me.ExpireAt))
+}
+
+// KeySet is a set of keys with their schedules. It is safe for concurrent
+// use.
+type KeySet struct {
+	// Clock is used to determine the state of the keys.
+	Clock clock.Time
+	mutex sync.RWMutex
+	keys  []Key
+}
+
+// New creates a new KeySet with keys. The ID of each key must be unique and
+// non-empty.
+func New(keys ...Key) (*KeySet, error) {
+	ks := &KeySet{Clock: clock.DefaultTime{}}
+	for _, key := range keys {
+		if err := ks.Add(key); err != nil {
+			return nil, err
+		}
+	}
+	return ks, nil
+}
+
+// Add adds key to the set.
+func (me *KeySet) Add(key Key) error {
+	if key.ID == "" {
+		return errors.New("key ID must not be empty")
+	}
+	if key.Signer == nil && key.Verifier == nil {
+		return fmt.Errorf("key %q has neither Signer nor Verifier", key.ID)
+	}
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	for _, k := range me.keys {
+		if k.ID == key.ID {
+			return fmt.Errorf("key %q already exists", key.ID)
+		}
+	}
+	me.keys = append(me.keys, key)
+	return nil
+}
+
+// Prune removes the keys that can't verify the tokens anymore.
+func (me *KeySet) Prune() {
+	now := me.Clock.Now()
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	keys := me.keys[:0]
+	for _, key := range me.keys {
+		if key.ExpireAt.IsZero() || now.Before(key.ExpireAt) {
+			keys = append(keys, key)
+		}
+	}
+	me.keys = keys
+}
+
+// Keys returns a copy of the keys in the set.
+func (me *KeySet) Keys() []Key {
+	me.mutex.RLock()
+	defer me.mutex.RUnlock()
+	return append([]Key(nil), me.keys...)
+}
+
+// CurrentSigner returns the ID and the signer of the active key that became
+// active most recently. If there's no active key, ErrNoActiveKey is
+// returned.
+func (me *KeySet) CurrentSigner() (string, jwt.Signer, error) {
+	now := me.Clock.Now()
+	me.mutex.RLock()
+	defer me.mutex.RUnlock()
+	var current *Key
+	for i, key := range me.keys {
+		if key.IsActive(now) &&
+			(current == nil || !key.NotBefore.Before(current.NotBefore)) {
+			current = &me.keys[i]
+		}
+	}
+	if current == nil {
+		return "", nil, ErrNoActiveKey
+	}
+	return current.ID, current.Signer, nil
+}
+
+// VerifierFor returns the verifier of the key identified by kid. If the key
+// doesn't exist or can't verify the tokens now, ErrUnknownKey is returned.
+func (me *KeySet) VerifierFor(kid string) (jwt.Verifier, error) {
+	now := me.Clock.Now()
+	me.mutex.RLock()
+	defer me.mutex.RUnlock()
+	for _, key := range me.keys {
+		if key.ID == kid && key.CanVerify(now) {
+			return key.verifier(), nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownKey, kid)
+}
+
+// Name implements jwt.Signer with the current key. It returns an empty
+// string if there's no active key.
+func (me *KeySet) Name() string {
+	_, signer, err := me.CurrentSigner()
+	if err != nil {
+		return ""
+	}
+	return signer.Name()
+}
+
+// Size implements jwt.Signer with the current key. It returns 0 if there's
+// no active key.
+func (me *KeySet) Size() int {
+	_, signer, err := me.CurrentSigner()
+	if err != nil {
+		return 0
+	}
+	return signer.Size()
+}
+
+// Sign implements jwt.Signer with the current key. Note that this doesn't
+// stamp "kid" header; core package does it by calling CurrentSigner.
+func (me *KeySet) Sign(payload []byte) ([]byte, error) {
+	_, signer, err := me.CurrentSigner()
+	if err != nil {
+		return nil, err
+	}
+	return signer.Sign(payload)
+}
+
+// Verify implements jwt.Verifier for the tokens without "kid" header.
+// It tries all the keys that can verify the tokens now.
+func (me *KeySet) Verify(payload, signature []byte) error {
+	now := me.Clock.Now()
+	me.mutex.RLock()
+	defer me.mutex.RUnlock()
+	err := ErrUnknownKey
+	for _, key := range me.keys {
+		if !key.CanVerify(now) {
+			continue
+		}
+		if err = key.verifier().Verify(payload, signature); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+var (
+	_ jwt.Signer   = (*KeySet)(nil)
+	_ jwt.Verifier = (*KeySet)(nil)
+)
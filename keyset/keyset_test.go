@@ -0,0 +1,94 @@
+package keyset_test
+
+import (
+	"testing"
+	"time"
+
+	"codeberg.org/gbrlsnchs/jwt"
+	"github.com/hiroaki-yamamoto/gauth/keyset"
+	"gotest.tools/v3/assert"
+)
+
+// Key set test
+
+func mustHS256(k string) jwt.Signer {
+	for len(k) < 32 {
+		k += "0"
+	}
+	s, err := jwt.NewHS256([]byte(k))
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+type TimeMock struct {
+	Time time.Time
+}
+
+func (me TimeMock) Now() time.Time {
+	return me.Time
+}
+
+func TestKeySetRotation(t *testing.T) {
+	now := time.Now().UTC()
+	rotateAt := now.Add(time.Hour)
+	ks, err := keyset.New(
+		keyset.Key{
+			ID: "old", Signer: mustHS256("old"),
+			RetireAt: rotateAt, ExpireAt: rotateAt.Add(time.Hour),
+		},
+		keyset.Key{ID: "new", Signer: mustHS256("new"), NotBefore: rotateAt},
+	)
+	assert.NilError(t, err)
+	payload := []byte("payload")
+
+	t.Run("Before rotation", func(t *testing.T) {
+		ks.Clock = TimeMock{now}
+		kid, _, err := ks.CurrentSigner()
+		assert.NilError(t, err)
+		assert.Equal(t, kid, "old")
+		_, err = ks.VerifierFor("new")
+		assert.ErrorIs(t, err, keyset.ErrUnknownKey)
+	})
+	t.Run("After rotation", func(t *testing.T) {
+		ks.Clock = TimeMock{now}
+		sig, err := ks.Sign(payload)
+		assert.NilError(t, err)
+
+		ks.Clock = TimeMock{rotateAt}
+		kid, _, err := ks.CurrentSigner()
+		assert.NilError(t, err)
+		assert.Equal(t, kid, "new")
+		verifier, err := ks.VerifierFor("old")
+		assert.NilError(t, err)
+		assert.NilError(t, verifier.Verify(payload, sig))
+		assert.NilError(t, ks.Verify(payload, sig))
+	})
+	t.Run("After expiration", func(t *testing.T) {
+		ks.Clock = TimeMock{rotateAt.Add(time.Hour)}
+		_, err := ks.VerifierFor("old")
+		assert.ErrorIs(t, err, keyset.ErrUnknownKey)
+		ks.Prune()
+		assert.Equal(t, len(ks.Keys()), 1)
+	})
+	t.Run("No active key", func(t *testing.T) {
+		ks.Clock = TimeMock{now.Add(-time.Hour)}
+		_, err := ks.Sign(payload)
+		assert.ErrorIs(t, err, keyset.ErrNoActiveKey)
+		assert.Equal(t, ks.Name(), "")
+		assert.Equal(t, ks.Size(), 0)
+	})
+}
+
+func TestKeySetAdd(t *testing.T) {
+	_, err := keyset.New(keyset.Key{Signer: mustHS256("test")})
+	assert.Error(t, err, "key ID must not be empty")
+	_, err = keyset.New(keyset.Key{ID: "test"})
+	assert.Error(t, err, `key "test" has neither Signer nor Verifier`)
+	_, err = keyset.New(
+		keyset.Key{ID: "test", Signer: mustHS256("test")},
+		keyset.Key{ID: "test", Signer: mustHS256("test")},
+	)
+	assert.Error(t, err, `key "test" already exists`)
+}
@@ -0,0 +1,220 @@
+package keyset
+
+// Remote JSON Web Key Set verifier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"codeberg.org/gbrlsnchs/jwt"
+	"github.com/hiroaki-yamamoto/gauth/clock"
+)
+
+const (
+	// DefaultRemoteTTL is the cache lifetime that is used when Remote.TTL is
+	// zero.
+	DefaultRemoteTTL = time.Hour
+	// DefaultRemoteMinInterval is the minimum interval between the fetches
+	// that is used when Remote.MinInterval is zero.
+	DefaultRemoteMinInterval = time.Minute
+	// DefaultRemoteMaxBackoff is the maximum interval between the retries
+	// after failures that is used when Remote.MaxBackoff is zero.
+	DefaultRemoteMaxBackoff = 30 * time.Minute
+	// MaxRemoteSize is the maximum size of the set that Remote reads.
+	MaxRemoteSize = 1 << 20
+)
+
+// Remote is a verifier that verifies the tokens with the keys loaded from
+// a JSON Web Key Set published by JWKSHandler (or any other JWKS endpoint).
+//
+// The keys are cached for TTL. When a token has an unknown "kid" header, the
+// set is fetched again, but not more often than MinInterval so that
+// the tokens with random "kid" can't flood the endpoint. After a failure, the
+// next fetch is delayed exponentially up to MaxBackoff while the stale keys
+// are kept in use. Only one fetch runs at a time, and the tokens are verified
+// with the cached keys while it runs, unless they need the fetched ones.
+//
+// Remote implements core.KeyedVerifier and jwt.Verifier, so it can be set to
+// config.Config.Verifier. Use NewRemote to create it.
+type Remote struct {
+	// URL is the URL of the JSON Web Key Set.
+	URL string
+	// Client is used to fetch the set.
+	Client *http.Client
+	// Clock is used to determine the age of the cache.
+	Clock clock.Time
+	// TTL is the lifetime of the cache.
+	TTL time.Duration
+	// MinInterval is the minimum interval between the fetches.
+	MinInterval time.Duration
+	// MaxBackoff is the maximum interval between the retries after failures.
+	MaxBackoff time.Duration
+
+	mutex       sync.Mutex
+	keys        map[string]jwt.Verifier
+	fetchedAt   time.Time
+	nextAttempt time.Time
+	failures    int
+	lastErr     error
+	// fetching is closed when the running fetch completes. It is nil when
+	// no fetch runs.
+	fetching chan struct{}
+}
+
+// NewRemote creates a new Remote that loads the set from url.
+func NewRemote(url string) *Remote {
+	return &Remote{
+		URL:    url,
+		Client: &http.Client{Timeout: 10 * time.Second},
+		Clock:  clock.DefaultTime{},
+	}
+}
+
+func orDefault(value, def time.Duration) time.Duration {
+	if value > 0 {
+		return value
+	}
+	return def
+}
+
+// Refresh fetches the set regardless of the cache and the backoff.
+func (me *Remote) Refresh(ctx context.Context) error {
+	now := me.Clock.Now()
+	keys, err := me.load(ctx)
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	return me.update(now, keys, err)
+}
+
+// update stores the result of the fetch that started at now. The caller
+// must hold the mutex.
+func (me *Remote) update(
+	now time.Time, keys map[string]jwt.Verifier, err error,
+) error {
+	if err != nil {
+		me.failures++
+		backoff := orDefault(me.MinInterval, DefaultRemoteMinInterval)
+		maxBackoff := orDefault(me.MaxBackoff, DefaultRemoteMaxBackoff)
+		for i := 1; i < me.failures && backoff < maxBackoff; i++ {
+			backoff *= 2
+		}
+		me.nextAttempt = now.Add(min(backoff, maxBackoff))
+		me.lastErr = err
+		return err
+	}
+	me.keys = keys
+	me.fetchedAt = now
+	me.failures = 0
+	me.lastErr = nil
+	me.nextAttempt = now.Add(orDefault(me.MinInterval, DefaultRemoteMinInterval))
+	return nil
+}
+
+func (me *Remote) load(ctx context.Context) (map[string]jwt.Verifier, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, me.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := me.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch JWKS: %s", res.Status)
+	}
+	var set JWKSet
+	body := io.LimitReader(res.Body, MaxRemoteSize)
+	if err = json.NewDecoder(body).Decode(&set); err != nil {
+		return nil, err
+	}
+	keys := map[string]jwt.Verifier{}
+	for _, key := range set.Keys {
+		// Unsupported keys are skipped so that a new key type in the set
+		// doesn't break the verification with the supported ones.
+		verifier, err := key.Verifier()
+		if err != nil || key.KeyID == "" {
+			continue
+		}
+		keys[key.KeyID] = verifier
+	}
+	return keys, nil
+}
+
+// ensure fetches the set if the cache is expired or force is true, unless
+// the fetch is suppressed by MinInterval or the backoff. If another fetch is
+// running, it waits for that fetch instead, unless the stale keys can be
+// used. The caller must hold the mutex, which is released during the fetch.
+func (me *Remote) ensure(force bool) {
+	now := me.Clock.Now()
+	expired := me.keys == nil ||
+		!now.Before(me.fetchedAt.Add(orDefault(me.TTL, DefaultRemoteTTL)))
+	if !force && !expired {
+		return
+	}
+	if fetching := me.fetching; fetching != nil {
+		if !force && me.keys != nil {
+			// The stale keys are used until the fetch completes.
+			return
+		}
+		me.mutex.Unlock()
+		<-fetching
+		me.mutex.Lock()
+		return
+	}
+	if now.Before(me.nextAttempt) {
+		return
+	}
+	fetching := make(chan struct{})
+	me.fetching = fetching
+	me.mutex.Unlock()
+	keys, err := me.load(context.Background())
+	me.mutex.Lock()
+	me.fetching = nil
+	close(fetching)
+	me.update(now, keys, err)
+}
+
+// VerifierFor implements core.KeyedVerifier.
+func (me *Remote) VerifierFor(kid string) (jwt.Verifier, error) {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	me.ensure(false)
+	if verifier, ok := me.keys[kid]; ok {
+		return verifier, nil
+	}
+	me.ensure(true)
+	if verifier, ok := me.keys[kid]; ok {
+		return verifier, nil
+	}
+	if me.lastErr != nil {
+		return nil, fmt.Errorf("%w: %q (%v)", ErrUnknownKey, kid, me.lastErr)
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownKey, kid)
+}
+
+// Verify implements jwt.Verifier for the tokens without "kid" header.
+// It tries all the cached keys.
+func (me *Remote) Verify(payload, signature []byte) error {
+	me.mutex.Lock()
+	me.ensure(false)
+	verifiers := make([]jwt.Verifier, 0, len(me.keys))
+	for _, verifier := range me.keys {
+		verifiers = append(verifiers, verifier)
+	}
+	me.mutex.Unlock()
+	err := ErrUnknownKey
+	for _, verifier := range verifiers {
+		if err = verifier.Verify(payload, signature); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+var _ jwt.Verifier = (*Remote)(nil)
@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/hiroaki-yamamoto/gauth/config"
+	"github.com/hiroaki-yamamoto/gauth/models"
 )
 
 // Authentication required (or not) middleware
@@ -15,5 +16,35 @@ func LoginRequired(
 	findUserFunc FindUser,
 	conf *config.Config,
 ) func(http.Handler) http.Handler {
-	return middlewareBase(con, findUserFunc, conf, true)
+	return middlewareBase(
+		userConverter(con, findUserFunc, conf),
+		loginRenewer(conf), conf, true,
+	)
+}
+
+// LoginRequiredWithClaims is the same as LoginRequired, but it also adds the
+// custom claims T of the token to http.Request.Context like
+// ContextMiddlewareWithClaims.
+func LoginRequiredWithClaims[T any](
+	con interface{},
+	findUserFunc FindUser,
+	conf *config.Config,
+) func(http.Handler) http.Handler {
+	return middlewareBase(
+		claimsConverter[T](con, findUserFunc, conf),
+		claimsRenewer[T](conf), conf, true,
+	)
+}
+
+// LoginRequiredCtx is the same as LoginRequired, but it looks up the user
+// with findUserFunc that takes the context of the request like
+// ContextMiddlewareCtx.
+func LoginRequiredCtx[U models.IUser](
+	findUserFunc FindUserCtx[U],
+	conf *config.Config,
+) func(http.Handler) http.Handler {
+	return middlewareBase(
+		ctxConverter(findUserFunc, conf),
+		loginRenewer(conf), conf, true,
+	)
 }
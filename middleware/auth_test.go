@@ -2,8 +2,15 @@ package middleware_test
 
 import (
 	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
+	"codeberg.org/gbrlsnchs/jwt"
+	"gotest.tools/v3/assert"
+
+	_conf "github.com/hiroaki-yamamoto/gauth/config"
+	"github.com/hiroaki-yamamoto/gauth/core"
 	mid "github.com/hiroaki-yamamoto/gauth/middleware"
 )
 
@@ -30,3 +37,30 @@ func TestCookieLoginRequiredMiddleware(t *testing.T) {
 		},
 	)
 }
+
+func TestVerifyOnlyLoginRequired(t *testing.T) {
+	signer := mustHS256("test")
+	conf, err := _conf.New(
+		"session", _conf.Header, nil,
+		"Test Audience", "Test Issuer", "Test Subject",
+		time.Hour, _conf.CookieConfig{},
+	)
+	assert.NilError(t, err)
+	conf.Verifier = signer.(jwt.Verifier)
+	issuer := *conf
+	issuer.Signer = signer
+	token, err := core.ComposeID("test_username", &issuer)
+	assert.NilError(t, err)
+	findUser := func(con interface{}, ID string) (interface{}, error) {
+		return User{UserBase{Username: ID}}, nil
+	}
+	handler := mid.LoginRequired(nil, findUser, conf)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+	)
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(conf.SessionName, string(token))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, rec.Code, http.StatusOK)
+	assert.Equal(t, rec.Header().Get("X-"+conf.SessionName), "")
+}
@@ -0,0 +1,168 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	_conf "github.com/hiroaki-yamamoto/gauth/config"
+	"github.com/hiroaki-yamamoto/gauth/core"
+	"github.com/hiroaki-yamamoto/gauth/models"
+)
+
+// Role- and permission-based authorization middleware
+
+// ErrForbidden is wrapped by the errors of the requests that are
+// authenticated, but not authorized. The error responders respond with 403
+// (Forbidden) for them.
+var ErrForbidden = errors.New("forbidden")
+
+// errAnonymous is the error of the request without the user. It wraps
+// core.ErrNoToken as well, so that the anonymous requests are responded and
+// logged as the ones without the token.
+var errAnonymous = fmt.Errorf(
+	"%w: %w", core.ErrNotAuthenticated, core.ErrNoToken,
+)
+
+// ScopeError is the error of the request whose token doesn't have the
+// scopes that RequireScopes requires.
+type ScopeError struct {
+	// Missing is the required scopes that the token is not granted.
+	Missing []string
+}
+
+func (me *ScopeError) Error() string {
+	return "insufficient scope: " + strings.Join(me.Missing, " ")
+}
+
+// Unwrap returns ErrForbidden.
+func (me *ScopeError) Unwrap() error {
+	return ErrForbidden
+}
+
+// RequireRoles returns a middleware that allows the user who has any of
+// roles, including the ones inherited through config.RoleHierarchy. The
+// user must implement models.RoleHolder. Place it after LoginRequired, or
+// its variants, that sets the user to the request context.
+//
+// The anonymous requests are responded with 401 (Not Authenticated), and the
+// users without the roles are responded with 403 (Forbidden).
+func RequireRoles(
+	config *_conf.Config,
+	roles ...string,
+) func(http.Handler) http.Handler {
+	return Require(config, func(_ *http.Request, user models.IUser) error {
+		holder, ok := user.(models.RoleHolder)
+		if ok {
+			granted := config.ExpandRoles(holder.GetRoles())
+			for _, role := range roles {
+				if granted[role] {
+					return nil
+				}
+			}
+		}
+		return fmt.Errorf(
+			"%w: one of the roles %q is required", ErrForbidden, roles,
+		)
+	})
+}
+
+// RequirePermissions returns a middleware that allows the user who has all
+// of permissions. The user must implement models.PermissionHolder. Like
+// RequireRoles, it must be placed after LoginRequired.
+func RequirePermissions(
+	config *_conf.Config,
+	permissions ...string,
+) func(http.Handler) http.Handler {
+	return Require(config, func(_ *http.Request, user models.IUser) error {
+		granted := map[string]bool{}
+		if holder, ok := user.(models.PermissionHolder); ok {
+			for _, permission := range holder.GetPermissions() {
+				granted[permission] = true
+			}
+		}
+		for _, permission := range permissions {
+			if !granted[permission] {
+				return fmt.Errorf(
+					"%w: permission %q is required", ErrForbidden, permission,
+				)
+			}
+		}
+		return nil
+	})
+}
+
+// RequireScopes returns a middleware that allows the token that is granted
+// all of scopes in "scope" claim. The granted scopes match the required
+// ones with wildcards as core.ScopeMatches describes. Like RequireRoles, it
+// must be placed after LoginRequired.
+//
+// The denied requests are responded with 403 (Forbidden) and ScopeError.
+// In Bearer mode, WWW-Authenticate header also tells the missing scopes
+// with "insufficient_scope" error as RFC 6750 specifies.
+func RequireScopes(
+	config *_conf.Config,
+	scopes ...string,
+) func(http.Handler) http.Handler {
+	return Require(config, func(r *http.Request, _ models.IUser) error {
+		missing := core.MissingScopes(GetScopes(r.Context()), scopes)
+		if len(missing) > 0 {
+			return &ScopeError{Missing: missing}
+		}
+		return nil
+	})
+}
+
+// Require returns a middleware that serves the requests of the users that
+// check allows, i.e. returns nil. The error of check should wrap
+// ErrForbidden so that it is responded with 403 (Forbidden). This is the
+// building block of RequireRoles and the others, and it must be placed after
+// LoginRequired as well.
+func Require(
+	config *_conf.Config,
+	check func(r *http.Request, user models.IUser) error,
+) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, ok := GetUser(r.Context()).(models.IUser)
+			if !ok && config.MiddlewareType == _conf.Bearer {
+				processBearerError(w, r, next, errAnonymous, config, true)
+				return
+			}
+			if !ok {
+				processError(w, r, next, errAnonymous, config, true)
+				return
+			}
+			if err := check(r, user); err != nil {
+				processForbidden(w, r, user, err, config)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// processForbidden responds to the request of user that is not authorized.
+func processForbidden(
+	w http.ResponseWriter,
+	r *http.Request,
+	user models.IUser,
+	err error,
+	config *_conf.Config,
+) {
+	recordForbidden(r, config, user, err)
+	var serr *ScopeError
+	if config.MiddlewareType == _conf.Bearer && errors.As(err, &serr) {
+		w.Header().Set("WWW-Authenticate", insufficientScope(serr))
+	}
+	handler := config.ErrorHandler
+	if handler == nil && config.MiddlewareType == _conf.Bearer {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if handler == nil {
+		handler = GraphQLError
+	}
+	handler(w, r, err)
+}
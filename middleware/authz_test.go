@@ -0,0 +1,109 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+
+	_conf "github.com/hiroaki-yamamoto/gauth/config"
+	"github.com/hiroaki-yamamoto/gauth/core"
+	mid "github.com/hiroaki-yamamoto/gauth/middleware"
+)
+
+// Authorization middleware test
+
+type StaffUser struct {
+	User
+	Roles       []string
+	Permissions []string
+}
+
+func (me StaffUser) GetRoles() []string {
+	return me.Roles
+}
+
+func (me StaffUser) GetPermissions() []string {
+	return me.Permissions
+}
+
+func TestAuthorization(t *testing.T) {
+	conf, err := _conf.New(
+		"session", _conf.Header, mustHS256("test"),
+		"Test Audience", "Test Issuer", "Test Subject",
+		time.Hour, _conf.CookieConfig{},
+	)
+	assert.NilError(t, err)
+	conf.RoleHierarchy = map[string][]string{"admin": {"editor"}}
+	users := map[string]StaffUser{
+		"alice": {
+			User: User{UserBase{Username: "alice"}}, Roles: []string{"admin"},
+			Permissions: []string{"posts:read", "posts:write"},
+		},
+		"bob": {
+			User: User{UserBase{Username: "bob"}}, Roles: []string{"viewer"},
+			Permissions: []string{"posts:read"},
+		},
+	}
+	findUser := func(con interface{}, ID string) (interface{}, error) {
+		return users[ID], nil
+	}
+	serve := func(
+		authz func(http.Handler) http.Handler, ID string,
+	) *httptest.ResponseRecorder {
+		handler := mid.LoginRequired(Con{}, findUser, conf)(
+			authz(handlerFunc),
+		)
+		req := httptest.NewRequest("GET", "/", nil)
+		if ID != "" {
+			token, err := core.ComposeID(ID, conf)
+			assert.NilError(t, err)
+			req.Header.Set(conf.SessionName, string(token))
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Inherited role", func(t *testing.T) {
+		rec := serve(mid.RequireRoles(conf, "editor"), "alice")
+		assert.Equal(t, rec.Code, http.StatusOK)
+	})
+	t.Run("Any of roles", func(t *testing.T) {
+		rec := serve(mid.RequireRoles(conf, "editor", "viewer"), "bob")
+		assert.Equal(t, rec.Code, http.StatusOK)
+	})
+	t.Run("Missing role", func(t *testing.T) {
+		rec := serve(mid.RequireRoles(conf, "editor"), "bob")
+		assert.Equal(t, rec.Code, http.StatusForbidden)
+		assert.Equal(
+			t, rec.Body.String(), `{"errors":[{"message":"Forbidden."}]}`+"\n",
+		)
+	})
+	t.Run("Permissions", func(t *testing.T) {
+		authz := mid.RequirePermissions(conf, "posts:read", "posts:write")
+		assert.Equal(t, serve(authz, "alice").Code, http.StatusOK)
+		assert.Equal(t, serve(authz, "bob").Code, http.StatusForbidden)
+	})
+	t.Run("Without roles", func(t *testing.T) {
+		users["carol"] = StaffUser{User: User{UserBase{Username: "carol"}}}
+		rec := serve(mid.RequireRoles(conf, "viewer"), "carol")
+		assert.Equal(t, rec.Code, http.StatusForbidden)
+	})
+	t.Run("Anonymous", func(t *testing.T) {
+		authz := mid.RequireRoles(conf, "viewer")
+		rec := httptest.NewRecorder()
+		mid.ContextMiddleware(Con{}, findUser, conf)(authz(handlerFunc)).
+			ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+		assert.Equal(t, rec.Code, http.StatusUnauthorized)
+	})
+	t.Run("Problem JSON", func(t *testing.T) {
+		conf := *conf
+		conf.ErrorHandler = mid.ProblemJSON
+		rec := serve(mid.RequireRoles(&conf, "editor"), "bob")
+		assert.Equal(t, rec.Code, http.StatusForbidden)
+		assert.Assert(t, rec.Body.Len() > 0)
+	})
+}
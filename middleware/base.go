@@ -1,8 +1,6 @@
 package middleware
 
 import (
-	"encoding/json"
-	"log"
 	"net/http"
 
 	"github.com/hiroaki-yamamoto/gauth/config"
@@ -11,33 +9,62 @@ import (
 	"github.com/hiroaki-yamamoto/gauth/models"
 )
 
-// Error represents an error.
-type Error struct {
-	Message string `json:"message,omitempty"`
-}
-
 func processError(
 	w http.ResponseWriter,
 	r *http.Request,
 	next http.Handler,
 	err error,
+	config *_conf.Config,
 	failOnError bool,
 ) {
-	log.Print(err)
-	if failOnError {
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string][]Error{
-			"errors": []Error{Error{"Not Authorized."}},
-		})
+	recordFailure(r, config, err)
+	if !failOnError {
+		next.ServeHTTP(w, r)
 		return
 	}
+	handler := config.ErrorHandler
+	if handler == nil {
+		handler = GraphQLError
+	}
+	handler(w, r, err)
+}
+
+// converter converts the token to the user, and returns the request whose
+// context holds the user.
+type converter func(r *http.Request, token string) (
+	*http.Request, interface{}, error,
+)
+
+// renewer renews the token of the user that is converted by converter.
+type renewer func(w http.ResponseWriter, r *http.Request, user models.IUser) error
+
+// serveUser renews the token of the user converted by converter, and serves
+// the request.
+func serveUser(
+	w http.ResponseWriter,
+	r *http.Request,
+	next http.Handler,
+	user interface{},
+	renew renewer,
+	config *_conf.Config,
+) {
+	iuser, ok := user.(models.IUser)
+	if ok {
+		recordAuthenticated(r, config, iuser)
+		if err := renew(w, r, iuser); err != nil {
+			recordRenewFailure(r, config, iuser, err)
+		}
+	} else {
+		config.Log().WarnContext(
+			r.Context(), "authorized user not detected", requestAttrs(r)...,
+		)
+	}
 	next.ServeHTTP(w, r)
 }
 
 func cookieMiddlewareBase(
-	con interface{},
-	findUserFunc FindUser,
+	convert converter,
+	renew renewer,
 	config *_conf.Config,
 	failOnError bool,
 ) func(http.Handler) http.Handler {
@@ -45,64 +72,54 @@ func cookieMiddlewareBase(
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			c, err := r.Cookie(config.SessionName)
 			if err != nil {
-				processError(w, r, next, err, failOnError)
+				processError(w, r, next, core.ErrNoToken, config, failOnError)
 				return
 			}
-			user, err := JwtToUser(c.Value, findUserFunc, con, config)
+			req, user, err := convert(r, c.Value)
 			if err != nil {
-				processError(w, r, next, err, failOnError)
+				processError(w, r, next, err, config, failOnError)
 				return
 			}
-			iuser, ok := user.(models.IUser)
-			if ok {
-				core.Login(w, config, iuser)
-				// There's nothing errors in this case. Therefore, no need to
-				// check whether the error is nil or not.
-			} else {
-				log.Println("Authorized user not detected")
-			}
-			next.ServeHTTP(w, SetUser(r, user))
+			serveUser(w, req, next, user, renew, config)
 		})
 	}
 }
 
 func headerMiddlewareBase(
-	con interface{},
-	findUserFunc FindUser,
+	convert converter,
+	renew renewer,
 	config *_conf.Config,
 	failOnError bool,
 ) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			c := r.Header.Get(config.SessionName)
-			user, err := JwtToUser(c, findUserFunc, con, config)
-			if err != nil {
-				processError(w, r, next, err, failOnError)
+			if c == "" {
+				processError(w, r, next, core.ErrNoToken, config, failOnError)
 				return
 			}
-
-			iuser, ok := user.(models.IUser)
-			if ok {
-				core.Login(w, config, iuser)
-				// There's nothing errors in this case. Therefore, no need to
-				// check whether the error is nil or not.
-			} else {
-				log.Println("Authorized user not detected")
+			req, user, err := convert(r, c)
+			if err != nil {
+				processError(w, r, next, err, config, failOnError)
+				return
 			}
-
-			next.ServeHTTP(w, SetUser(r, user))
+			serveUser(w, req, next, user, renew, config)
 		})
 	}
 }
 
 func middlewareBase(
-	con interface{},
-	findUserFunc FindUser,
+	convert converter,
+	renew renewer,
 	conf *config.Config,
 	failOnError bool,
 ) func(http.Handler) http.Handler {
-	if conf.MiddlewareType == config.Header {
-		return headerMiddlewareBase(con, findUserFunc, conf, failOnError)
+	switch conf.MiddlewareType {
+	case config.Header:
+		return headerMiddlewareBase(convert, renew, conf, failOnError)
+	case config.Bearer:
+		return bearerMiddlewareBase(convert, renew, conf, failOnError)
+	default:
+		return cookieMiddlewareBase(convert, renew, conf, failOnError)
 	}
-	return cookieMiddlewareBase(con, findUserFunc, conf, failOnError)
 }
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	_conf "github.com/hiroaki-yamamoto/gauth/config"
+	"github.com/hiroaki-yamamoto/gauth/core"
+)
+
+// Bearer token middleware (RFC 6750)
+
+// processBearerError is the same as processError, but it also sets
+// WWW-Authenticate header as RFC 6750 specifies. The error code is omitted
+// if the request doesn't have the token. If Config.ErrorHandler is nil, the
+// body of the response is empty.
+func processBearerError(
+	w http.ResponseWriter,
+	r *http.Request,
+	next http.Handler,
+	err error,
+	config *_conf.Config,
+	failOnError bool,
+) {
+	recordFailure(r, config, err)
+	if !failOnError {
+		next.ServeHTTP(w, r)
+		return
+	}
+	challenge := "Bearer"
+	if !errors.Is(err, core.ErrNoToken) {
+		challenge += ` error="invalid_token", error_description="` +
+			describeError(err) + `"`
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	if config.ErrorHandler != nil {
+		config.ErrorHandler(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusUnauthorized)
+}
+
+// bearerParam removes the characters that are not allowed in the
+// parameters of RFC 6750 from value.
+func bearerParam(value string) string {
+	return strings.Map(func(c rune) rune {
+		if c < 0x20 || c > 0x7e || c == '"' || c == '\\' {
+			return -1
+		}
+		return c
+	}, value)
+}
+
+// insufficientScope returns the challenge of WWW-Authenticate header for
+// the token that lacks the scopes.
+func insufficientScope(err *ScopeError) string {
+	return `Bearer error="insufficient_scope", error_description="` +
+		"The token lacks the required scopes" + `", scope="` +
+		bearerParam(strings.Join(err.Missing, " ")) + `"`
+}
+
+func bearerMiddlewareBase(
+	convert converter,
+	renew renewer,
+	config *_conf.Config,
+	failOnError bool,
+) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := core.BearerToken(r)
+			if token == "" {
+				processBearerError(w, r, next, core.ErrNoToken, config, failOnError)
+				return
+			}
+			req, user, err := convert(r, token)
+			if err != nil {
+				processBearerError(w, r, next, err, config, failOnError)
+				return
+			}
+			serveUser(w, req, next, user, renew, config)
+		})
+	}
+}
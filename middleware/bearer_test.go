@@ -0,0 +1,93 @@
+package middleware_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+
+	_conf "github.com/hiroaki-yamamoto/gauth/config"
+	"github.com/hiroaki-yamamoto/gauth/core"
+	mid "github.com/hiroaki-yamamoto/gauth/middleware"
+)
+
+// Bearer token test
+
+func TestBearerLoginRequired(t *testing.T) {
+	conf, err := _conf.New(
+		"session", _conf.Bearer, mustHS256("test"),
+		"Test Audience", "Test Issuer", "Test Subject",
+		time.Hour, _conf.CookieConfig{},
+	)
+	assert.NilError(t, err)
+	token, err := core.ComposeID("test_username", conf)
+	assert.NilError(t, err)
+	handler := mid.LoginRequired(Con{}, findTestUser, conf)(handlerFunc)
+	serve := func(authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Valid token", func(t *testing.T) {
+		rec := serve("Bearer " + string(token))
+		assert.Equal(t, rec.Code, http.StatusOK)
+		assert.Equal(t, rec.Header().Get("WWW-Authenticate"), "")
+		assert.Equal(t, rec.Header().Get("X-"+conf.SessionName), "")
+	})
+	t.Run("Case-insensitive scheme", func(t *testing.T) {
+		rec := serve("bearer " + string(token))
+		assert.Equal(t, rec.Code, http.StatusOK)
+	})
+	t.Run("No token", func(t *testing.T) {
+		rec := serve("")
+		assert.Equal(t, rec.Code, http.StatusUnauthorized)
+		assert.Equal(t, rec.Header().Get("WWW-Authenticate"), "Bearer")
+	})
+	t.Run("Other scheme", func(t *testing.T) {
+		rec := serve("Basic dXNlcjpwYXNz")
+		assert.Equal(t, rec.Code, http.StatusUnauthorized)
+		assert.Equal(t, rec.Header().Get("WWW-Authenticate"), "Bearer")
+	})
+	t.Run("Invalid token", func(t *testing.T) {
+		rec := serve("Bearer " + string(token) + "x")
+		assert.Equal(t, rec.Code, http.StatusUnauthorized)
+		assert.Assert(t, rec.Body.Len() == 0)
+		assert.Equal(
+			t, rec.Header().Get("WWW-Authenticate"),
+			`Bearer error="invalid_token", error_description="The signature of the token is invalid"`,
+		)
+	})
+	t.Run("Internal error", func(t *testing.T) {
+		handler := mid.LoginRequired(
+			Con{}, func(con interface{}, username string) (interface{}, error) {
+				return nil, errors.New("db: connection refused")
+			}, conf,
+		)(handlerFunc)
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", "Bearer "+string(token))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, rec.Code, http.StatusUnauthorized)
+		assert.Equal(
+			t, rec.Header().Get("WWW-Authenticate"),
+			`Bearer error="invalid_token", error_description="The token is invalid"`,
+		)
+	})
+	t.Run("Context middleware passes through", func(t *testing.T) {
+		handler := mid.ContextMiddleware(Con{}, findTestUser, conf)(handlerFunc)
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", "Bearer invalid")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, rec.Code, http.StatusOK)
+		assert.Equal(t, rec.Header().Get("WWW-Authenticate"), "")
+	})
+}
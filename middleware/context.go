@@ -6,7 +6,11 @@ import (
 	"context"
 	"net/http"
 
+	"codeberg.org/gbrlsnchs/jwt"
 	_conf "github.com/hiroaki-yamamoto/gauth/config"
+	"github.com/hiroaki-yamamoto/gauth/core"
+	"github.com/hiroaki-yamamoto/gauth/models"
+	"github.com/hiroaki-yamamoto/gauth/store"
 )
 
 type contextkey struct {
@@ -14,12 +18,25 @@ type contextkey struct {
 }
 
 var userCtxKey = &contextkey{"user"}
+var claimsCtxKey = &contextkey{"claims"}
+var tokenCtxKey = &contextkey{"token"}
+var sessionCtxKey = &contextkey{"session"}
+var sessionIDCtxKey = &contextkey{"sessionID"}
+var scopesCtxKey = &contextkey{"scopes"}
+var configCtxKey = &contextkey{"config"}
 
 // GetUser get user from context
 func GetUser(ctx context.Context) interface{} {
 	return ctx.Value(userCtxKey)
 }
 
+// GetUserAs get user of type U from context. The second value is false if
+// the context doesn't have the user of type U.
+func GetUserAs[U any](ctx context.Context) (U, bool) {
+	user, ok := ctx.Value(userCtxKey).(U)
+	return user, ok
+}
+
 // SetUser set user to context
 func SetUser(
 	r *http.Request,
@@ -28,6 +45,92 @@ func SetUser(
 	return r.WithContext(context.WithValue(r.Context(), userCtxKey, user))
 }
 
+// GetClaims get the custom claims of the token from context. The second
+// value is false if the context doesn't have the claims of type T.
+func GetClaims[T any](ctx context.Context) (T, bool) {
+	claims, ok := ctx.Value(claimsCtxKey).(T)
+	return claims, ok
+}
+
+// GetCustomClaims get the custom claims of the token from context regardless
+// of its type. Use GetClaims to get the claims of the known type.
+func GetCustomClaims(ctx context.Context) any {
+	return ctx.Value(claimsCtxKey)
+}
+
+// SetClaims set the custom claims of the token to context
+func SetClaims[T any](r *http.Request, claims T) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), claimsCtxKey, claims))
+}
+
+// GetSession get the server-side session from context. The second value is
+// false if the request is not authenticated with the session, i.e. the
+// middleware type is not config.Session. Save the modified session with
+// config.Config.SessionStore.
+func GetSession(ctx context.Context) (*store.Session, bool) {
+	session, ok := ctx.Value(sessionCtxKey).(*store.Session)
+	return session, ok
+}
+
+// SetSession set the server-side session to context
+func SetSession(r *http.Request, session *store.Session) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), sessionCtxKey, session))
+}
+
+// GetSessionID get the ID of the current session from context, i.e. the ID
+// of the server-side session or "sid" claim of the token. Pass it to
+// core.RevokeOtherSessions to sign out the other devices. The empty string
+// is returned if the session isn't recorded on config.Config.SessionStore.
+func GetSessionID(ctx context.Context) string {
+	ID, _ := ctx.Value(sessionIDCtxKey).(string)
+	return ID
+}
+
+func setSessionID(r *http.Request, ID string) *http.Request {
+	if ID == "" {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), sessionIDCtxKey, ID))
+}
+
+// GetScopes get the scopes of the token from context. See core.Claims.Scope.
+func GetScopes(ctx context.Context) []string {
+	scopes, _ := ctx.Value(scopesCtxKey).([]string)
+	return scopes
+}
+
+// SetScopes set the scopes of the token to context
+func SetScopes(r *http.Request, scopes []string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), scopesCtxKey, scopes))
+}
+
+// GetConfig get the Config that Resolve resolved from context. It is nil if
+// the request is not served by Resolve.
+func GetConfig(ctx context.Context) *_conf.Config {
+	conf, _ := ctx.Value(configCtxKey).(*_conf.Config)
+	return conf
+}
+
+// SetConfig set the Config to context
+func SetConfig(r *http.Request, conf *_conf.Config) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), configCtxKey, conf))
+}
+
+// getToken get the extracted token from context. This is used to renew
+// the token.
+func getToken[T any](ctx context.Context) (*jwt.JWT[core.CustomClaims[T]], bool) {
+	token, ok := ctx.Value(tokenCtxKey).(*jwt.JWT[core.CustomClaims[T]])
+	return token, ok
+}
+
+// setToken set the extracted token to context
+func setToken[T any](
+	r *http.Request,
+	token *jwt.JWT[core.CustomClaims[T]],
+) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), tokenCtxKey, token))
+}
+
 // ContextMiddleware adds the authenticated user to http.Request.Context.
 // if there's the token in the specified header / cookie in config.
 func ContextMiddleware(
@@ -35,5 +138,35 @@ func ContextMiddleware(
 	findUserFunc FindUser,
 	config *_conf.Config,
 ) func(http.Handler) http.Handler {
-	return middlewareBase(con, findUserFunc, config, false)
+	return middlewareBase(
+		userConverter(con, findUserFunc, config),
+		loginRenewer(config), config, false,
+	)
+}
+
+// ContextMiddlewareWithClaims is the same as ContextMiddleware, but it also
+// adds the custom claims T of the token to http.Request.Context. The claims
+// can be retrieved by GetClaims[T]. The renewed token keeps the claims.
+func ContextMiddlewareWithClaims[T any](
+	con interface{},
+	findUserFunc FindUser,
+	config *_conf.Config,
+) func(http.Handler) http.Handler {
+	return middlewareBase(
+		claimsConverter[T](con, findUserFunc, config),
+		claimsRenewer[T](config), config, false,
+	)
+}
+
+// ContextMiddlewareCtx is the same as ContextMiddleware, but it looks up the
+// user with findUserFunc that takes the context of the request. The user can
+// be retrieved by GetUserAs[U].
+func ContextMiddlewareCtx[U models.IUser](
+	findUserFunc FindUserCtx[U],
+	config *_conf.Config,
+) func(http.Handler) http.Handler {
+	return middlewareBase(
+		ctxConverter(findUserFunc, config),
+		loginRenewer(config), config, false,
+	)
 }
@@ -3,6 +3,7 @@ package middleware_test
 // Context test
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
@@ -13,7 +14,6 @@ import (
 	"codeberg.org/gbrlsnchs/jwt"
 	"gotest.tools/v3/assert"
 
-	"github.com/hiroaki-yamamoto/gauth/clock"
 	"github.com/hiroaki-yamamoto/gauth/config"
 	_conf "github.com/hiroaki-yamamoto/gauth/config"
 	"github.com/hiroaki-yamamoto/gauth/core"
@@ -79,8 +79,8 @@ func cookieTest(
 	srvHandler *http.Handler,
 	autoExtend bool,
 ) func(t *testing.T) {
-	clock.Clock = TimeMock{time.Unix(time.Now().Unix(), 0).UTC()}
-	now := clock.Clock.Now()
+	conf.Clock = TimeMock{time.Unix(time.Now().Unix(), 0).UTC()}
+	now := conf.Now()
 	return func(t *testing.T) {
 		req := httptest.NewRequest("GET", "/", nil)
 		rec := httptest.NewRecorder()
@@ -124,7 +124,7 @@ func cookieTest(
 			assert.Equal(
 				t,
 				tok.Claims.Expiration.Time().UTC(),
-				clock.Clock.Now().Add(3600*time.Hour),
+				now.Add(3600*time.Hour),
 			)
 		} else {
 			assert.Assert(t, session == nil)
@@ -156,8 +156,8 @@ func headerTest(
 	srvHandler *http.Handler,
 	autoExtend bool,
 ) func(t *testing.T) {
-	clock.Clock = TimeMock{time.Unix(time.Now().Unix(), 0).UTC()}
-	now := clock.Clock.Now()
+	conf.Clock = TimeMock{time.Unix(time.Now().Unix(), 0).UTC()}
+	now := conf.Now()
 	return func(t *testing.T) {
 		req := httptest.NewRequest("GET", "/", nil)
 		rec := httptest.NewRecorder()
@@ -196,7 +196,7 @@ func headerTest(
 			assert.Equal(
 				t,
 				hdrTok.Claims.Expiration.Time().UTC(),
-				clock.Clock.Now().Add(3600*time.Hour),
+				now.Add(3600*time.Hour),
 			)
 			return
 		}
@@ -426,3 +426,116 @@ func TestCookieHandlerMiddleware(t *testing.T) {
 		[]mid.Error(nil),
 	)
 }
+
+type RoleClaims struct {
+	Role string `json:"role,omitzero"`
+}
+
+func TestContextMiddlewareWithClaims(t *testing.T) {
+	conf, err := _conf.New(
+		"session", _conf.Cookie, mustHS256("test"),
+		"Test Audience", "Test Issuer", "Test Subject",
+		time.Hour, _conf.CookieConfig{Path: "/"},
+	)
+	assert.NilError(t, err)
+	var gotClaims RoleClaims
+	var gotOK bool
+	handler := mid.LoginRequiredWithClaims[RoleClaims](
+		&Con{}, func(con interface{}, username string) (interface{}, error) {
+			return User{UserBase{Username: username}}, nil
+		}, conf,
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotClaims, gotOK = mid.GetClaims[RoleClaims](r.Context())
+		assert.Equal(t, mid.GetUser(r.Context()).(User).Username, "test_username")
+	}))
+	token, err := core.ComposeClaims(
+		"test_username", RoleClaims{Role: "admin"}, conf,
+	)
+	assert.NilError(t, err)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(&http.Cookie{Name: conf.SessionName, Value: string(token)})
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, rec.Code, http.StatusOK)
+	assert.Assert(t, gotOK)
+	assert.Equal(t, gotClaims, RoleClaims{Role: "admin"})
+
+	t.Run("The renewed token keeps the claims", func(t *testing.T) {
+		cookies := rec.Result().Cookies()
+		assert.Equal(t, len(cookies), 1)
+		renewed, err := core.ExtractClaims[RoleClaims](cookies[0].Value, conf)
+		assert.NilError(t, err)
+		assert.Equal(t, renewed.Claims.Custom, RoleClaims{Role: "admin"})
+	})
+	t.Run("Claims of another type", func(t *testing.T) {
+		_, ok := mid.GetClaims[string](
+			mid.SetClaims(req, RoleClaims{}).Context(),
+		)
+		assert.Assert(t, !ok)
+	})
+}
+
+type requestIDKey struct{}
+
+func TestContextMiddlewareCtx(t *testing.T) {
+	conf, err := _conf.New(
+		"Authorization", _conf.Header, mustHS256("test"),
+		"Test Audience", "Test Issuer", "Test Subject",
+		time.Hour, _conf.CookieConfig{},
+	)
+	assert.NilError(t, err)
+	findUser := func(ctx context.Context, ID string) (User, error) {
+		if err := ctx.Err(); err != nil {
+			return User{}, err
+		}
+		assert.Equal(t, ctx.Value(requestIDKey{}), "request-id")
+		return User{UserBase{Username: ID}}, nil
+	}
+	token, err := core.ComposeID("test_username", conf)
+	assert.NilError(t, err)
+	newRequest := func(ctx context.Context) *http.Request {
+		req := httptest.NewRequest("GET", "/", nil).WithContext(ctx)
+		req.Header.Set(conf.SessionName, string(token))
+		return req
+	}
+	ctx := context.WithValue(context.Background(), requestIDKey{}, "request-id")
+
+	t.Run("Typed user", func(t *testing.T) {
+		var user User
+		var ok bool
+		handler := mid.ContextMiddlewareCtx(findUser, conf)(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				user, ok = mid.GetUserAs[User](r.Context())
+			},
+		))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, newRequest(ctx))
+		assert.Equal(t, rec.Code, http.StatusOK)
+		assert.Assert(t, ok)
+		assert.Equal(t, user.Username, "test_username")
+		assert.Assert(t, rec.Header().Get("X-"+conf.SessionName) != "")
+	})
+	t.Run("Renewal policy", func(t *testing.T) {
+		conf := *conf
+		conf.RenewalPolicy = _conf.RenewBeforeExpiry
+		conf.RenewThreshold = 10 * time.Minute
+		handler := mid.ContextMiddlewareCtx(findUser, &conf)(handlerFunc)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, newRequest(ctx))
+		assert.Equal(t, rec.Code, http.StatusOK)
+		assert.Equal(t, rec.Header().Get("X-"+conf.SessionName), "")
+	})
+	t.Run("Canceled request", func(t *testing.T) {
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+		handler := mid.LoginRequiredCtx(findUser, conf)(handlerFunc)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, newRequest(canceled))
+		assert.Equal(t, rec.Code, http.StatusUnauthorized)
+	})
+	t.Run("Anonymous", func(t *testing.T) {
+		_, ok := mid.GetUserAs[User](context.Background())
+		assert.Assert(t, !ok)
+	})
+}
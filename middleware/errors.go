@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/hiroaki-yamamoto/gauth/core"
+)
+
+// Error responders that can be set to config.Config.ErrorHandler
+
+// Error represents an error.
+type Error struct {
+	Message string `json:"message,omitempty"`
+}
+
+// Problem is the problem details of RFC 7807.
+type Problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// statusOf returns the status code of err, i.e. 403 (Forbidden) if err
+// wraps ErrForbidden, otherwise 401 (Not Authenticated).
+func statusOf(err error) int {
+	if errors.Is(err, ErrForbidden) {
+		return http.StatusForbidden
+	}
+	return http.StatusUnauthorized
+}
+
+// errorDescriptions is the fixed descriptions of the errors of the tokens,
+// checked in order.
+var errorDescriptions = []struct {
+	err         error
+	description string
+}{
+	{core.ErrNoToken, "The token is missing"},
+	{core.ErrMalformedToken, "The token is malformed"},
+	{core.ErrInvalidSignature, "The signature of the token is invalid"},
+	{core.ErrExpired, "The token is expired"},
+	{core.ErrNotActive, "The token is not active yet"},
+	{core.ErrIssuedInFuture, "The token is issued in the future"},
+	{core.ErrInvalidAudience, "The token is issued for another audience"},
+	{core.ErrInvalidIssuer, "The token is issued by another issuer"},
+	{core.ErrInvalidSubject, "The token is issued for another subject"},
+	{core.ErrInvalidTenant, "The token is issued for another tenant"},
+	{core.ErrRefreshAsAccess, "The refresh token can't be used for this"},
+	{core.ErrRevoked, "The token is revoked"},
+	{core.ErrStaleToken, "The token is outdated"},
+	{ErrForbidden, "The access is forbidden"},
+}
+
+// describeError returns the fixed description of err, so that the internal
+// errors, e.g. the ones of FindUser, are not exposed to the clients. The
+// description only consists of the characters allowed in error_description
+// of RFC 6750.
+func describeError(err error) string {
+	for _, desc := range errorDescriptions {
+		if errors.Is(err, desc.err) {
+			return desc.description
+		}
+	}
+	return "The token is invalid"
+}
+
+// GraphQLError responds with 401 (Not Authenticated) and the errors in the
+// style of GraphQL, i.e. {"errors": [{"message": "Not Authorized."}]}.
+// The errors of the authorization are responded with 403 (Forbidden) and
+// "Forbidden." message instead. This is the default responder.
+func GraphQLError(w http.ResponseWriter, r *http.Request, err error) {
+	status, message := statusOf(err), "Not Authorized."
+	if status == http.StatusForbidden {
+		message = "Forbidden."
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string][]Error{
+		"errors": []Error{Error{message}},
+	})
+}
+
+// ProblemJSON responds with 401 (Not Authenticated), or 403 (Forbidden) for
+// the errors of the authorization, and the problem details of RFC 7807 in
+// application/problem+json. The detail is the fixed description of err
+// instead of its message, so that the internal errors are not exposed.
+func ProblemJSON(w http.ResponseWriter, r *http.Request, err error) {
+	status := statusOf(err)
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: describeError(err),
+	})
+}
+
+// PlainText responds with 401 (Not Authenticated), or 403 (Forbidden) for
+// the errors of the authorization, and its status text in text/plain.
+func PlainText(w http.ResponseWriter, r *http.Request, err error) {
+	status := statusOf(err)
+	http.Error(w, http.StatusText(status), status)
+}
@@ -0,0 +1,98 @@
+package middleware_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+
+	_conf "github.com/hiroaki-yamamoto/gauth/config"
+	mid "github.com/hiroaki-yamamoto/gauth/middleware"
+)
+
+// Error responder test
+
+func serveUnauthorized(
+	t *testing.T,
+	typ _conf.MiddlewareType,
+	handler _conf.ErrorHandler,
+) *httptest.ResponseRecorder {
+	conf, err := _conf.New(
+		"session", typ, mustHS256("test"),
+		"Test Audience", "Test Issuer", "Test Subject",
+		time.Hour, _conf.CookieConfig{},
+	)
+	assert.NilError(t, err)
+	conf.ErrorHandler = handler
+	rec := httptest.NewRecorder()
+	mid.LoginRequired(Con{}, findTestUser, conf)(handlerFunc).ServeHTTP(
+		rec, httptest.NewRequest("GET", "/private", nil),
+	)
+	return rec
+}
+
+func TestErrorHandler(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		rec := serveUnauthorized(t, _conf.Cookie, nil)
+		assert.Equal(t, rec.Code, http.StatusUnauthorized)
+		assert.Equal(t, rec.Header().Get("Content-Type"), "application/json")
+		assert.Equal(
+			t, rec.Body.String(),
+			`{"errors":[{"message":"Not Authorized."}]}`+"\n",
+		)
+	})
+	t.Run("Problem JSON", func(t *testing.T) {
+		rec := serveUnauthorized(t, _conf.Header, mid.ProblemJSON)
+		assert.Equal(t, rec.Code, http.StatusUnauthorized)
+		assert.Equal(
+			t, rec.Header().Get("Content-Type"), "application/problem+json",
+		)
+		var problem mid.Problem
+		assert.NilError(t, json.NewDecoder(rec.Body).Decode(&problem))
+		assert.DeepEqual(t, problem, mid.Problem{
+			Type:   "about:blank",
+			Title:  "Unauthorized",
+			Status: http.StatusUnauthorized,
+			Detail: "The token is missing",
+		})
+	})
+	t.Run("Problem JSON hides internal errors", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		mid.ProblemJSON(
+			rec, httptest.NewRequest("GET", "/private", nil),
+			errors.New("db: connection refused"),
+		)
+		var problem mid.Problem
+		assert.NilError(t, json.NewDecoder(rec.Body).Decode(&problem))
+		assert.Equal(t, problem.Detail, "The token is invalid")
+
+		rec = httptest.NewRecorder()
+		mid.ProblemJSON(
+			rec, httptest.NewRequest("GET", "/private", nil),
+			&mid.ScopeError{Missing: []string{"admin"}},
+		)
+		assert.NilError(t, json.NewDecoder(rec.Body).Decode(&problem))
+		assert.Equal(t, problem.Status, http.StatusForbidden)
+		assert.Equal(t, problem.Detail, "The access is forbidden")
+	})
+	t.Run("Plain text", func(t *testing.T) {
+		rec := serveUnauthorized(t, _conf.Cookie, mid.PlainText)
+		assert.Equal(t, rec.Code, http.StatusUnauthorized)
+		assert.Equal(
+			t, rec.Header().Get("Content-Type"), "text/plain; charset=utf-8",
+		)
+		assert.Equal(t, rec.Body.String(), "Unauthorized\n")
+	})
+	t.Run("Bearer keeps WWW-Authenticate", func(t *testing.T) {
+		rec := serveUnauthorized(t, _conf.Bearer, mid.ProblemJSON)
+		assert.Equal(t, rec.Code, http.StatusUnauthorized)
+		assert.Equal(t, rec.Header().Get("WWW-Authenticate"), "Bearer")
+		assert.Equal(
+			t, rec.Header().Get("Content-Type"), "application/problem+json",
+		)
+	})
+}
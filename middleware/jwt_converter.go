@@ -1,10 +1,15 @@
 package middleware
 
 import (
-	"errors"
+	"context"
+	"net/http"
+
+	"codeberg.org/gbrlsnchs/jwt"
 
 	_conf "github.com/hiroaki-yamamoto/gauth/config"
 	"github.com/hiroaki-yamamoto/gauth/core"
+	"github.com/hiroaki-yamamoto/gauth/models"
+	"github.com/hiroaki-yamamoto/gauth/store"
 )
 
 // JWT to user converter
@@ -12,23 +17,238 @@ import (
 // FindUser represents a function to find a user by username,
 type FindUser func(con interface{}, username string) (interface{}, error)
 
+// FindUserCtx represents a function to find a user of type U by ID. ctx is
+// the context of the request, so the lookup should honor its cancellation.
+type FindUserCtx[U models.IUser] func(ctx context.Context, ID string) (U, error)
+
 // JwtToUser converts jwStr to the corresponding user.
+// The user is looked up by "uid" claim. For the tokens without "uid" claim,
+// i.e. the tokens composed by core.ComposeToken, "jti" claim is used instead.
+// If the user implements models.TokenVersioner, the tokens that were issued
+// for an older token version are rejected with core.ErrStaleToken.
 func JwtToUser(
 	jwtStr string,
 	findUserFunc FindUser,
 	con interface{},
 	config *_conf.Config,
 ) (interface{}, error) {
-	token, err := core.ExtractToken(jwtStr, config)
+	user, _, err := JwtToUserWithClaims[jwt.None](
+		jwtStr, findUserFunc, con, config,
+	)
+	return user, err
+}
+
+// JwtToUserWithClaims is the same as JwtToUser, but it also returns the
+// custom claims T of the token.
+func JwtToUserWithClaims[T any](
+	jwtStr string,
+	findUserFunc FindUser,
+	con interface{},
+	config *_conf.Config,
+) (interface{}, T, error) {
+	user, cred, err := jwtToUser[T](jwtStr, findUserFunc, con, config)
 	if err != nil {
-		return nil, err
+		var claims T
+		return nil, claims, err
+	}
+	return user, cred.claims(), nil
+}
+
+// JwtToUserCtx is the same as JwtToUser, but it looks up the user with
+// findUserFunc that takes ctx and returns the user of type U.
+func JwtToUserCtx[U models.IUser](
+	ctx context.Context,
+	jwtStr string,
+	findUserFunc FindUserCtx[U],
+	config *_conf.Config,
+) (U, error) {
+	user, _, err := jwtToUserCtx(ctx, jwtStr, findUserFunc, config)
+	return user, err
+}
+
+// credential is the verified token or the session of the request.
+type credential[T any] struct {
+	userID    string
+	sessionID string
+	token     *jwt.JWT[core.CustomClaims[T]]
+	session   *store.Session
+}
+
+// claims returns the custom claims of the token. The zero value is returned
+// for the session.
+func (me *credential[T]) claims() T {
+	if me.token == nil {
+		var claims T
+		return claims
 	}
-	if len(token.Claims.JWTID) < 1 {
-		return nil, errors.New("Not authenticated user")
+	return me.token.Claims.Custom.Custom
+}
+
+// checkUser checks the token version of the token against user that is
+// looked up by the user ID.
+func (me *credential[T]) checkUser(user any, config *_conf.Config) error {
+	if me.token == nil {
+		return nil
+	}
+	return core.CheckTokenVersion(
+		config, user, me.token.Claims.Custom.Claims, me.token.Claims.JWTID,
+	)
+}
+
+// attach stores the token or the session to the request context so that
+// the renewer can renew it.
+func (me *credential[T]) attach(r *http.Request) *http.Request {
+	r = setSessionID(r, me.sessionID)
+	if me.session != nil {
+		return SetSession(r, me.session)
+	}
+	r = SetScopes(r, me.token.Claims.Custom.Scopes())
+	return setToken(r, me.token)
+}
+
+func jwtToUser[T any](
+	jwtStr string,
+	findUserFunc FindUser,
+	con interface{},
+	config *_conf.Config,
+) (interface{}, *credential[T], error) {
+	cred, err := authenticate[T](jwtStr, config)
+	if err != nil {
+		return nil, nil, err
 	}
-	user, err := findUserFunc(con, token.Claims.JWTID)
+	user, err := findUserFunc(con, cred.userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = cred.checkUser(user, config); err != nil {
+		return nil, nil, err
+	}
+	return user, cred, nil
+}
+
+func jwtToUserCtx[U models.IUser](
+	ctx context.Context,
+	jwtStr string,
+	findUserFunc FindUserCtx[U],
+	config *_conf.Config,
+) (U, *credential[jwt.None], error) {
+	cred, err := authenticate[jwt.None](jwtStr, config)
+	if err != nil {
+		var zero U
+		return zero, nil, err
+	}
+	user, err := findUserFunc(ctx, cred.userID)
+	if err != nil {
+		return user, nil, err
+	}
+	if err = cred.checkUser(user, config); err != nil {
+		var zero U
+		return zero, nil, err
+	}
+	return user, cred, nil
+}
+
+// authenticate extracts the user ID and the token with the custom claims T
+// from jwtStr. In Session mode, jwtStr is the session ID, and the session
+// is looked up instead.
+func authenticate[T any](
+	jwtStr string,
+	config *_conf.Config,
+) (*credential[T], error) {
+	if config.MiddlewareType == _conf.Session {
+		session, err := core.ExtractSession(jwtStr, config)
+		if err != nil {
+			return nil, err
+		}
+		return &credential[T]{
+			userID: session.UserID, sessionID: session.ID, session: session,
+		}, nil
+	}
+	token, err := core.ExtractClaims[core.CustomClaims[T]](jwtStr, config)
 	if err != nil {
 		return nil, err
 	}
-	return user, nil
+	ID := token.Claims.Custom.UserID
+	if ID == "" {
+		ID = token.Claims.JWTID
+	}
+	if len(ID) < 1 {
+		return nil, &core.ValidationError{
+			Claim: "uid", Err: core.ErrNotAuthenticated,
+		}
+	}
+	return &credential[T]{
+		userID: ID, sessionID: token.Claims.Custom.SessionID, token: token,
+	}, nil
+}
+
+func userConverter(
+	con interface{},
+	findUserFunc FindUser,
+	config *_conf.Config,
+) converter {
+	return func(r *http.Request, token string) (
+		*http.Request, interface{}, error,
+	) {
+		user, cred, err := jwtToUser[jwt.None](token, findUserFunc, con, config)
+		if err != nil {
+			return nil, nil, err
+		}
+		return cred.attach(SetUser(r, user)), user, nil
+	}
+}
+
+func loginRenewer(config *_conf.Config) renewer {
+	return claimsRenewer[jwt.None](config)
+}
+
+func claimsConverter[T any](
+	con interface{},
+	findUserFunc FindUser,
+	config *_conf.Config,
+) converter {
+	return func(r *http.Request, token string) (
+		*http.Request, interface{}, error,
+	) {
+		user, cred, err := jwtToUser[T](token, findUserFunc, con, config)
+		if err != nil {
+			return nil, nil, err
+		}
+		r = SetClaims(SetUser(r, user), cred.claims())
+		return cred.attach(r), user, nil
+	}
+}
+
+// claimsRenewer renews the token or the session that is stored to the
+// request context by the converter according to Config.RenewalPolicy.
+// Nothing is renewed in Bearer mode, as the standard clients don't read the
+// token from the response headers.
+func claimsRenewer[T any](config *_conf.Config) renewer {
+	return func(w http.ResponseWriter, r *http.Request, user models.IUser) error {
+		if config.MiddlewareType == _conf.Bearer {
+			return nil
+		}
+		if session, ok := GetSession(r.Context()); ok {
+			_, err := core.RenewSession(w, config, session)
+			return err
+		}
+		jot, _ := getToken[T](r.Context())
+		_, err := core.Renew(w, config, user, jot)
+		return err
+	}
+}
+
+func ctxConverter[U models.IUser](
+	findUserFunc FindUserCtx[U],
+	config *_conf.Config,
+) converter {
+	return func(r *http.Request, token string) (
+		*http.Request, interface{}, error,
+	) {
+		user, cred, err := jwtToUserCtx(r.Context(), token, findUserFunc, config)
+		if err != nil {
+			return nil, nil, err
+		}
+		return cred.attach(SetUser(r, user)), user, nil
+	}
 }
@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/hiroaki-yamamoto/gauth/audit"
+	_conf "github.com/hiroaki-yamamoto/gauth/config"
+	"github.com/hiroaki-yamamoto/gauth/core"
+	"github.com/hiroaki-yamamoto/gauth/models"
+)
+
+// Structured logging and audit of the authentication events
+
+// requestAttrs returns the attributes of r followed by attrs.
+func requestAttrs(r *http.Request, attrs ...any) []any {
+	return append([]any{
+		slog.String("remote_addr", r.RemoteAddr),
+		slog.String("method", r.Method),
+		slog.String("path", r.URL.Path),
+	}, attrs...)
+}
+
+// recordFailure logs the reason why the request is not authenticated, and
+// emits audit.Rejected event. The requests without the token are logged in
+// debug level without the event because they are usual for anonymous users.
+func recordFailure(r *http.Request, config *_conf.Config, err error) {
+	level := slog.LevelInfo
+	if errors.Is(err, core.ErrNoToken) {
+		level = slog.LevelDebug
+	}
+	attrs := []any{slog.String("reason", err.Error())}
+	var verr *core.ValidationError
+	if errors.As(err, &verr) {
+		attrs = append(attrs, slog.String("claim", verr.Claim))
+		if verr.JWTID != "" {
+			attrs = append(attrs, slog.String("jti", verr.JWTID))
+		}
+	}
+	config.Log().Log(
+		r.Context(), level, "authentication failed",
+		requestAttrs(r, attrs...)...,
+	)
+	if !errors.Is(err, core.ErrNoToken) {
+		event := audit.Event{
+			Type: audit.Rejected, Reason: err.Error(), RemoteAddr: r.RemoteAddr,
+		}
+		if verr != nil {
+			event.JWTID = verr.JWTID
+		}
+		config.Emit(event)
+	}
+}
+
+// recordAuthenticated logs the authenticated user, and emits
+// audit.Authenticated event.
+func recordAuthenticated(
+	r *http.Request,
+	config *_conf.Config,
+	user models.IUser,
+) {
+	config.Log().DebugContext(
+		r.Context(), "authenticated",
+		requestAttrs(r, slog.String("user_id", user.GetID()))...,
+	)
+	config.Emit(audit.Event{
+		Type: audit.Authenticated, UserID: user.GetID(),
+		RemoteAddr: r.RemoteAddr,
+	})
+}
+
+// recordForbidden logs the reason why the request of user is not
+// authorized, and emits audit.Rejected event.
+func recordForbidden(
+	r *http.Request,
+	config *_conf.Config,
+	user models.IUser,
+	err error,
+) {
+	config.Log().InfoContext(
+		r.Context(), "authorization failed",
+		requestAttrs(
+			r, slog.String("user_id", user.GetID()),
+			slog.String("reason", err.Error()),
+		)...,
+	)
+	config.Emit(audit.Event{
+		Type: audit.Rejected, UserID: user.GetID(), Reason: err.Error(),
+		RemoteAddr: r.RemoteAddr,
+	})
+}
+
+func recordRenewFailure(
+	r *http.Request,
+	config *_conf.Config,
+	user models.IUser,
+	err error,
+) {
+	config.Log().ErrorContext(
+		r.Context(), "failed to renew the token",
+		requestAttrs(
+			r, slog.String("user_id", user.GetID()), slog.Any("error", err),
+		)...,
+	)
+}
@@ -0,0 +1,125 @@
+package middleware_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/hiroaki-yamamoto/gauth/audit"
+	"github.com/hiroaki-yamamoto/gauth/clock"
+	_conf "github.com/hiroaki-yamamoto/gauth/config"
+	"github.com/hiroaki-yamamoto/gauth/core"
+	mid "github.com/hiroaki-yamamoto/gauth/middleware"
+)
+
+// Structured logging test
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	fake := clock.NewFake(time.Unix(time.Now().Unix(), 0).UTC())
+	conf, err := _conf.New(
+		"session", _conf.Header, mustHS256("test"),
+		"Test Audience", "Test Issuer", "Test Subject",
+		time.Hour, _conf.CookieConfig{},
+	)
+	assert.NilError(t, err)
+	conf.Clock = fake
+	conf.Logger = slog.New(slog.NewJSONHandler(
+		&buf, &slog.HandlerOptions{Level: slog.LevelDebug},
+	))
+	token, err := core.ComposeID("test_username", conf)
+	assert.NilError(t, err)
+	jot, err := core.ExtractAccess(string(token), conf)
+	assert.NilError(t, err)
+	handler := mid.LoginRequired(Con{}, findTestUser, conf)(handlerFunc)
+	serve := func(token string) map[string]any {
+		buf.Reset()
+		req := httptest.NewRequest("GET", "/private", nil)
+		if token != "" {
+			req.Header.Set(conf.SessionName, token)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		var event map[string]any
+		assert.NilError(t, json.Unmarshal(buf.Bytes(), &event))
+		return event
+	}
+
+	t.Run("Authenticated", func(t *testing.T) {
+		event := serve(string(token))
+		assert.Equal(t, event["level"], "DEBUG")
+		assert.Equal(t, event["msg"], "authenticated")
+		assert.Equal(t, event["user_id"], "test_username")
+		assert.Equal(t, event["remote_addr"], "192.0.2.1:1234")
+		assert.Equal(t, event["path"], "/private")
+	})
+	t.Run("No token", func(t *testing.T) {
+		event := serve("")
+		assert.Equal(t, event["level"], "DEBUG")
+		assert.Equal(t, event["msg"], "authentication failed")
+		assert.Equal(t, event["reason"], core.ErrNoToken.Error())
+	})
+	t.Run("Expired", func(t *testing.T) {
+		fake.Advance(2 * time.Hour)
+		defer fake.Advance(-2 * time.Hour)
+		event := serve(string(token))
+		assert.Equal(t, event["level"], "INFO")
+		assert.Equal(t, event["msg"], "authentication failed")
+		assert.Equal(t, event["reason"], core.ErrExpired.Error())
+		assert.Equal(t, event["claim"], "exp")
+		assert.Equal(t, event["jti"], jot.Claims.JWTID)
+	})
+	t.Run("Disabled", func(t *testing.T) {
+		conf := *conf
+		conf.Logger = slog.New(slog.DiscardHandler)
+		buf.Reset()
+		rec := httptest.NewRecorder()
+		mid.LoginRequired(Con{}, findTestUser, &conf)(handlerFunc).ServeHTTP(
+			rec, httptest.NewRequest("GET", "/private", nil),
+		)
+		assert.Equal(t, rec.Code, http.StatusUnauthorized)
+		assert.Equal(t, buf.Len(), 0)
+	})
+}
+
+func TestAuditEvents(t *testing.T) {
+	var events []audit.Event
+	conf, err := _conf.New(
+		"session", _conf.Header, mustHS256("test"),
+		"Test Audience", "Test Issuer", "Test Subject",
+		time.Hour, _conf.CookieConfig{},
+	)
+	assert.NilError(t, err)
+	conf.Logger = slog.New(slog.DiscardHandler)
+	conf.Hook = audit.HookFunc(func(event audit.Event) error {
+		events = append(events, event)
+		return nil
+	})
+	token, err := core.ComposeID("test_username", conf)
+	assert.NilError(t, err)
+	handler := mid.LoginRequired(Con{}, findTestUser, conf)(handlerFunc)
+	for _, token := range []string{string(token), "", "invalid"} {
+		req := httptest.NewRequest("GET", "/private", nil)
+		if token != "" {
+			req.Header.Set(conf.SessionName, token)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	types := []audit.EventType{}
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	assert.DeepEqual(t, types, []audit.EventType{
+		audit.Authenticated, audit.Renew,
+		audit.VerificationFailed, audit.Rejected,
+	})
+	assert.Equal(t, events[0].UserID, "test_username")
+	assert.Equal(t, events[0].RemoteAddr, "192.0.2.1:1234")
+	assert.Equal(t, events[3].RemoteAddr, "192.0.2.1:1234")
+}
@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	_conf "github.com/hiroaki-yamamoto/gauth/config"
+)
+
+// Redirect-to-login for browsers
+
+// NextParam is the name of the query parameter that holds the URL to return
+// to after the login.
+const NextParam = "next"
+
+// RedirectToLogin returns the responder that redirects the browser to
+// loginURL with 303 (See Other). The URL of the request is added to
+// loginURL as NextParam, so the login handler can send the user back with
+// Allowlist.Next after core.Login.
+// The errors of the authorization, i.e. the ones that wrap ErrForbidden,
+// are responded with 403 (Forbidden) by PlainText instead, because the
+// user is already logged in and the login would send them back here.
+func RedirectToLogin(loginURL string) _conf.ErrorHandler {
+	return func(w http.ResponseWriter, r *http.Request, err error) {
+		if errors.Is(err, ErrForbidden) {
+			PlainText(w, r, err)
+			return
+		}
+		u, perr := url.Parse(loginURL)
+		if perr != nil {
+			http.Redirect(w, r, loginURL, http.StatusSeeOther)
+			return
+		}
+		query := u.Query()
+		query.Set(NextParam, r.URL.RequestURI())
+		u.RawQuery = query.Encode()
+		http.Redirect(w, r, u.String(), http.StatusSeeOther)
+	}
+}
+
+// Allowlist validates the URL to return to after the login to prevent open
+// redirects. The zero value allows only the paths on the same host.
+type Allowlist struct {
+	// Hosts is the list of the hosts (with the port, if any) that the
+	// absolute URLs can point to. The comparison is case-insensitive.
+	Hosts []string
+	// Paths is the list of the path prefixes that the URL can point to.
+	// A prefix matches the path itself and the paths under it, e.g.
+	// "/app" matches "/app" and "/app/page", but not "/apple".
+	// If this is empty, any path is allowed.
+	Paths []string
+}
+
+// Allows returns true if next is safe to redirect to.
+func (me Allowlist) Allows(next string) bool {
+	if next == "" || strings.ContainsAny(next, "\\\r\n\t") {
+		return false
+	}
+	u, err := url.Parse(next)
+	if err != nil || u.Opaque != "" || u.User != nil {
+		return false
+	}
+	if u.Scheme != "" || u.Host != "" || strings.HasPrefix(next, "//") {
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return false
+		}
+		if !me.allowsHost(u.Host) {
+			return false
+		}
+	} else if !strings.HasPrefix(next, "/") {
+		return false
+	}
+	return me.allowsPath(u.Path)
+}
+
+func (me Allowlist) allowsHost(host string) bool {
+	for _, allowed := range me.Hosts {
+		if strings.EqualFold(host, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+func (me Allowlist) allowsPath(p string) bool {
+	if len(me.Paths) < 1 {
+		return true
+	}
+	if p == "" {
+		p = "/"
+	}
+	p = path.Clean(p)
+	for _, prefix := range me.Paths {
+		prefix = strings.TrimSuffix(prefix, "/")
+		if p == prefix || strings.HasPrefix(p, prefix+"/") || prefix == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Next returns the value of NextParam in the query or the form of r if
+// it's allowed, and fallback otherwise.
+func (me Allowlist) Next(r *http.Request, fallback string) string {
+	if next := r.FormValue(NextParam); me.Allows(next) {
+		return next
+	}
+	return fallback
+}
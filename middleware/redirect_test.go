@@ -0,0 +1,107 @@
+package middleware_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	_conf "github.com/hiroaki-yamamoto/gauth/config"
+	mid "github.com/hiroaki-yamamoto/gauth/middleware"
+)
+
+// Redirect-to-login test
+
+func TestRedirectToLogin(t *testing.T) {
+	for _, tc := range []struct {
+		name, loginURL, expected string
+	}{
+		{"Path", "/login", "/login?next=%2Fprivate%3Fpage%3D2"},
+		{
+			"With query", "https://auth.example.com/login?lang=en",
+			"https://auth.example.com/login?lang=en&next=%2Fprivate%3Fpage%3D2",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := serveUnauthorizedAt(
+				t, mid.RedirectToLogin(tc.loginURL), "/private?page=2",
+			)
+			assert.Equal(t, rec.Code, http.StatusSeeOther)
+			assert.Equal(t, rec.Header().Get("Location"), tc.expected)
+		})
+	}
+}
+
+func TestRedirectForbidden(t *testing.T) {
+	rec := httptest.NewRecorder()
+	mid.RedirectToLogin("/login")(
+		rec, httptest.NewRequest("GET", "/admin", nil),
+		fmt.Errorf("%w: admin role is required", mid.ErrForbidden),
+	)
+	assert.Equal(t, rec.Code, http.StatusForbidden)
+	assert.Equal(t, rec.Header().Get("Location"), "")
+}
+
+func serveUnauthorizedAt(
+	t *testing.T,
+	handler _conf.ErrorHandler,
+	target string,
+) *httptest.ResponseRecorder {
+	conf := &_conf.Config{
+		SessionName: "session", Signer: mustHS256("test"),
+		ErrorHandler: handler,
+	}
+	rec := httptest.NewRecorder()
+	mid.LoginRequired(Con{}, findTestUser, conf)(handlerFunc).ServeHTTP(
+		rec, httptest.NewRequest("GET", target, nil),
+	)
+	return rec
+}
+
+func TestAllowlist(t *testing.T) {
+	allow := mid.Allowlist{
+		Hosts: []string{"app.example.com", "localhost:8080"},
+		Paths: []string{"/app", "/account/"},
+	}
+	for _, tc := range []struct {
+		next    string
+		allowed bool
+	}{
+		{"/app", true},
+		{"/app/page?tab=1#top", true},
+		{"/account/settings", true},
+		{"https://APP.example.com/app/page", true},
+		{"http://localhost:8080/app", true},
+		{"", false},
+		{"/apple", false},
+		{"/admin", false},
+		{"/app/../admin", false},
+		{"app/page", false},
+		{"//evil.com/app", false},
+		{"/\\evil.com/app", false},
+		{"https://evil.com/app", false},
+		{"https://app.example.com.evil.com/app", false},
+		{"https://app.example.com@evil.com/app", false},
+		{"javascript:alert(1)", false},
+		{"https:evil.com", false},
+		{"/app\r\nSet-Cookie: x=y", false},
+	} {
+		t.Run(tc.next, func(t *testing.T) {
+			assert.Equal(t, allow.Allows(tc.next), tc.allowed)
+		})
+	}
+
+	t.Run("Zero value allows only the same host", func(t *testing.T) {
+		allow := mid.Allowlist{}
+		assert.Assert(t, allow.Allows("/any/path"))
+		assert.Assert(t, !allow.Allows("https://app.example.com/"))
+	})
+	t.Run("Next", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/login?next=%2Fapp%2Fpage", nil)
+		assert.Equal(t, allow.Next(req, "/"), "/app/page")
+		req = httptest.NewRequest("GET", "/login?next=https%3A%2F%2Fevil.com", nil)
+		assert.Equal(t, allow.Next(req, "/"), "/")
+	})
+}
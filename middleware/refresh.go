@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"net/http"
+
+	_conf "github.com/hiroaki-yamamoto/gauth/config"
+	"github.com/hiroaki-yamamoto/gauth/core"
+)
+
+// Refresh token endpoint
+
+// RefreshHandler returns a handler that exchanges the refresh token in the
+// header / cookie specified by config.RefreshSessionName for a new token
+// pair. The user of the token is looked up with findUserFunc so that the
+// deleted user can't refresh the tokens, and the token version of the
+// user is checked as well. On success, the handler responds
+// with 204 (No Content) and the new pair in the session fields; otherwise,
+// it responds with 401 (Not Authenticated).
+func RefreshHandler(
+	con interface{},
+	findUserFunc FindUser,
+	config *_conf.Config,
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, err := refreshTokenFromRequest(r, config)
+		if err != nil {
+			processError(w, r, nil, err, config, true)
+			return
+		}
+		refresh, err := core.ExtractRefresh(token, config)
+		if err != nil {
+			processError(w, r, nil, err, config, true)
+			return
+		}
+		user, err := findUserFunc(con, refresh.Claims.Custom.UserID)
+		if err == nil {
+			err = core.CheckTokenVersion(
+				config, user, refresh.Claims.Custom, refresh.Claims.JWTID,
+			)
+		}
+		if err != nil {
+			processError(w, r, nil, err, config, true)
+			return
+		}
+		pair, err := core.RotatePair(refresh, config)
+		if err != nil {
+			processError(w, r, nil, err, config, true)
+			return
+		}
+		core.SetPair(w, config, pair)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func refreshTokenFromRequest(
+	r *http.Request,
+	config *_conf.Config,
+) (string, error) {
+	name := config.RefreshSessionName()
+	if !config.MiddlewareType.UsesCookie() {
+		token := r.Header.Get(name)
+		if token == "" {
+			return "", core.ErrNoToken
+		}
+		return token, nil
+	}
+	c, err := r.Cookie(name)
+	if err != nil {
+		return "", core.ErrNoToken
+	}
+	return c.Value, nil
+}
@@ -0,0 +1,118 @@
+package middleware_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+
+	_conf "github.com/hiroaki-yamamoto/gauth/config"
+	"github.com/hiroaki-yamamoto/gauth/core"
+	mid "github.com/hiroaki-yamamoto/gauth/middleware"
+	"github.com/hiroaki-yamamoto/gauth/store"
+)
+
+// Refresh endpoint test
+
+func findTestUser(con interface{}, username string) (interface{}, error) {
+	return User{UserBase{Username: username}}, nil
+}
+
+func TestCookieRefreshHandler(t *testing.T) {
+	conf, err := _conf.New(
+		"session", _conf.Cookie, mustHS256("test"),
+		"Test Audience", "Test Issuer", "Test Subject",
+		time.Hour, _conf.CookieConfig{Path: "/"},
+	)
+	assert.NilError(t, err)
+	conf.RefreshStore = store.NewMemoryRefreshStore()
+	pair, err := core.ComposePair("test_username", conf)
+	assert.NilError(t, err)
+	handler := mid.RefreshHandler(&Con{}, findTestUser, conf)
+
+	refresh := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/refresh", nil)
+		req.AddCookie(&http.Cookie{
+			Name:  conf.RefreshSessionName(),
+			Value: token,
+		})
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := refresh(string(pair.Refresh))
+	assert.Equal(t, rec.Code, http.StatusNoContent)
+	cookies := map[string]*http.Cookie{}
+	for _, cookie := range rec.Result().Cookies() {
+		cookies[cookie.Name] = cookie
+	}
+	assert.Assert(t, cookies[conf.SessionName] != nil)
+	assert.Assert(t, cookies[conf.RefreshSessionName()] != nil)
+	_, err = core.ExtractToken(cookies[conf.SessionName].Value, conf)
+	assert.NilError(t, err)
+
+	t.Run("Replayed refresh token", func(t *testing.T) {
+		rec := refresh(string(pair.Refresh))
+		assert.Equal(t, rec.Code, http.StatusUnauthorized)
+		rec = refresh(cookies[conf.RefreshSessionName()].Value)
+		assert.Equal(t, rec.Code, http.StatusUnauthorized)
+	})
+	t.Run("Access token is not accepted", func(t *testing.T) {
+		rec := refresh(string(pair.Access))
+		assert.Equal(t, rec.Code, http.StatusUnauthorized)
+	})
+	t.Run("No refresh token", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("POST", "/refresh", nil))
+		assert.Equal(t, rec.Code, http.StatusUnauthorized)
+	})
+}
+
+func TestHeaderRefreshHandler(t *testing.T) {
+	conf, err := _conf.New(
+		"Authorization", _conf.Header, mustHS256("test"),
+		"Test Audience", "Test Issuer", "Test Subject",
+		time.Hour, _conf.CookieConfig{},
+	)
+	assert.NilError(t, err)
+	conf.RefreshStore = store.NewMemoryRefreshStore()
+	pair, err := core.ComposePair("test_username", conf)
+	assert.NilError(t, err)
+
+	t.Run("Success", func(t *testing.T) {
+		handler := mid.RefreshHandler(&Con{}, findTestUser, conf)
+		req := httptest.NewRequest("POST", "/refresh", nil)
+		req.Header.Set(conf.RefreshSessionName(), string(pair.Refresh))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, rec.Code, http.StatusNoContent)
+		_, err := core.ExtractRefresh(
+			rec.Header().Get("X-"+conf.RefreshSessionName()), conf,
+		)
+		assert.NilError(t, err)
+	})
+	t.Run("No refresh token", func(t *testing.T) {
+		handler := mid.RefreshHandler(&Con{}, findTestUser, conf)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("POST", "/refresh", nil))
+		assert.Equal(t, rec.Code, http.StatusUnauthorized)
+	})
+	t.Run("User not found", func(t *testing.T) {
+		pair, err := core.ComposePair("test_username", conf)
+		assert.NilError(t, err)
+		handler := mid.RefreshHandler(
+			&Con{}, func(interface{}, string) (interface{}, error) {
+				return nil, errors.New("Error Test")
+			}, conf,
+		)
+		req := httptest.NewRequest("POST", "/refresh", nil)
+		req.Header.Set(conf.RefreshSessionName(), string(pair.Refresh))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, rec.Code, http.StatusUnauthorized)
+	})
+}
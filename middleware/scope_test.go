@@ -0,0 +1,121 @@
+package middleware_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/hiroaki-yamamoto/gauth/audit"
+	_conf "github.com/hiroaki-yamamoto/gauth/config"
+	"github.com/hiroaki-yamamoto/gauth/core"
+	mid "github.com/hiroaki-yamamoto/gauth/middleware"
+)
+
+// Scope-enforcing middleware test
+
+func TestRequireScopes(t *testing.T) {
+	conf, err := _conf.New(
+		"Authorization", _conf.Bearer, mustHS256("test"),
+		"Test Audience", "Test Issuer", "Test Subject",
+		time.Hour, _conf.CookieConfig{},
+	)
+	assert.NilError(t, err)
+	token, err := core.ComposeScopes(
+		"test_username", []string{"orders:*", "profile"}, conf,
+	)
+	assert.NilError(t, err)
+	serve := func(
+		conf *_conf.Config, token string, scopes ...string,
+	) *httptest.ResponseRecorder {
+		handler := mid.LoginRequired(Con{}, findTestUser, conf)(
+			mid.RequireScopes(conf, scopes...)(handlerFunc),
+		)
+		req := httptest.NewRequest("GET", "/orders", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Granted", func(t *testing.T) {
+		rec := serve(conf, string(token), "orders:write", "profile")
+		assert.Equal(t, rec.Code, http.StatusOK)
+	})
+	t.Run("Insufficient scope", func(t *testing.T) {
+		rec := serve(conf, string(token), "orders:write", "admin", "billing")
+		assert.Equal(t, rec.Code, http.StatusForbidden)
+		assert.Equal(
+			t, rec.Header().Get("WWW-Authenticate"),
+			`Bearer error="insufficient_scope", `+
+				`error_description="The token lacks the required scopes", `+
+				`scope="admin billing"`,
+		)
+	})
+	t.Run("Error handler", func(t *testing.T) {
+		conf := *conf
+		var got error
+		conf.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+			got = err
+			mid.PlainText(w, r, err)
+		}
+		rec := serve(&conf, string(token), "admin")
+		assert.Equal(t, rec.Code, http.StatusForbidden)
+		var serr *mid.ScopeError
+		assert.Assert(t, errors.As(got, &serr))
+		assert.DeepEqual(t, serr.Missing, []string{"admin"})
+		assert.ErrorIs(t, got, mid.ErrForbidden)
+	})
+	t.Run("Anonymous", func(t *testing.T) {
+		conf := *conf
+		var events []audit.Event
+		conf.Hook = audit.HookFunc(func(event audit.Event) error {
+			events = append(events, event)
+			return nil
+		})
+		handler := mid.ContextMiddleware(Con{}, findTestUser, &conf)(
+			mid.RequireScopes(&conf, "profile")(handlerFunc),
+		)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/orders", nil))
+		assert.Equal(t, rec.Code, http.StatusUnauthorized)
+		assert.Equal(t, rec.Header().Get("WWW-Authenticate"), "Bearer")
+		assert.Equal(t, len(events), 0)
+	})
+	t.Run("Unscoped token", func(t *testing.T) {
+		token, err := core.ComposeID("test_username", conf)
+		assert.NilError(t, err)
+		rec := serve(conf, string(token), "profile")
+		assert.Equal(t, rec.Code, http.StatusForbidden)
+	})
+	t.Run("Renewed token keeps the scopes", func(t *testing.T) {
+		conf, err := _conf.New(
+			"session", _conf.Header, mustHS256("test"),
+			"Test Audience", "Test Issuer", "Test Subject",
+			time.Hour, _conf.CookieConfig{},
+		)
+		assert.NilError(t, err)
+		token, err := core.ComposeScopes(
+			"test_username", []string{"profile"}, conf,
+		)
+		assert.NilError(t, err)
+		handler := mid.LoginRequired(Con{}, findTestUser, conf)(
+			mid.RequireScopes(conf, "profile")(handlerFunc),
+		)
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set(conf.SessionName, string(token))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, rec.Code, http.StatusOK)
+		renewed, err := core.ExtractAccess(
+			rec.Header().Get("X-"+conf.SessionName), conf,
+		)
+		assert.NilError(t, err)
+		assert.Equal(t, renewed.Claims.Custom.Scope, "profile")
+	})
+}
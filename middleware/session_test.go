@@ -0,0 +1,107 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/hiroaki-yamamoto/gauth/clock"
+	_conf "github.com/hiroaki-yamamoto/gauth/config"
+	"github.com/hiroaki-yamamoto/gauth/core"
+	mid "github.com/hiroaki-yamamoto/gauth/middleware"
+	"github.com/hiroaki-yamamoto/gauth/store"
+)
+
+// Server-side session middleware test
+
+func TestSessionMiddleware(t *testing.T) {
+	fake := clock.NewFake(time.Unix(time.Now().Unix(), 0).UTC())
+	conf, err := _conf.New(
+		"session", _conf.Session, mustHS256("test"),
+		"Test Audience", "Test Issuer", "Test Subject",
+		time.Hour, _conf.CookieConfig{Path: "/"},
+	)
+	assert.NilError(t, err)
+	sessions := store.NewMemorySessionStore()
+	sessions.Clock = fake
+	conf.Clock, conf.SessionStore = fake, sessions
+	rec := httptest.NewRecorder()
+	assert.NilError(t, core.Login(rec, conf, User{UserBase{Username: "test"}}))
+	ID := rec.Result().Cookies()[0].Value
+
+	handler := mid.LoginRequired(Con{}, findTestUser, conf)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			session, ok := mid.GetSession(r.Context())
+			assert.Assert(t, ok)
+			assert.Equal(t, mid.GetUser(r.Context()).(User).GetID(), "test")
+			session.Data["visits"] += "x"
+			assert.NilError(t, conf.SessionStore.Save(session))
+		}),
+	)
+	serve := func(ID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.AddCookie(&http.Cookie{Name: conf.SessionName, Value: ID})
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Authenticated", func(t *testing.T) {
+		fake.Advance(time.Minute)
+		rec := serve(ID)
+		assert.Equal(t, rec.Code, http.StatusOK)
+		cookies := rec.Result().Cookies()
+		assert.Equal(t, len(cookies), 1)
+		assert.Equal(t, cookies[0].Value, ID)
+		serve(ID)
+		session, err := sessions.Get(ID)
+		assert.NilError(t, err)
+		assert.Equal(t, session.Data["visits"], "xx")
+		assert.Equal(t, session.ExpireAt, fake.Now().Add(time.Hour))
+	})
+	t.Run("Unknown session", func(t *testing.T) {
+		rec := serve("unknown")
+		assert.Equal(t, rec.Code, http.StatusUnauthorized)
+	})
+}
+
+func TestRevokedSession(t *testing.T) {
+	conf, err := _conf.New(
+		"session", _conf.Header, mustHS256("test"),
+		"Test Audience", "Test Issuer", "Test Subject",
+		time.Hour, _conf.CookieConfig{},
+	)
+	assert.NilError(t, err)
+	conf.SessionStore = store.NewMemorySessionStore()
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/login", nil)
+	user := User{UserBase{Username: "test"}}
+	assert.NilError(t, core.LoginRequest(rec, req, conf, user))
+	token := rec.Header().Get("X-" + conf.SessionName)
+
+	var sessionID string
+	handler := mid.LoginRequired(Con{}, findTestUser, conf)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sessionID = mid.GetSessionID(r.Context())
+		}),
+	)
+	serve := func() int {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set(conf.SessionName, token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, serve(), http.StatusOK)
+	sessions, err := core.ListSessions(conf, "test")
+	assert.NilError(t, err)
+	assert.Equal(t, len(sessions), 1)
+	assert.Equal(t, sessionID, sessions[0].ID)
+
+	assert.NilError(t, core.RevokeAllSessions(conf, "test"))
+	assert.Equal(t, serve(), http.StatusUnauthorized)
+}
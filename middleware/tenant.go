@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"sync"
+
+	_conf "github.com/hiroaki-yamamoto/gauth/config"
+)
+
+// Multi-tenant middleware
+
+// Resolve returns a middleware that resolves the Config of the request with
+// resolver, and serves the request with the middleware that build returns
+// for the Config, e.g.
+//
+//	middleware.Resolve(resolver, func(conf *config.Config) func(http.Handler) http.Handler {
+//		return middleware.LoginRequired(con, findUser, conf)
+//	})
+//
+// The middleware is built once per Config. The Config is also set to the
+// request context, so that the handlers can log the users in to the
+// resolved tenant with GetConfig. If build is nil, only the Config is set.
+//
+// The requests that resolver fails to resolve are responded with
+// GraphQLError, i.e. 401 (Not Authenticated), because their Config, and
+// thus Config.ErrorHandler, is unknown.
+func Resolve(
+	resolver _conf.ConfigResolver,
+	build func(conf *_conf.Config) func(http.Handler) http.Handler,
+) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		var handlers sync.Map
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			conf, err := resolver.Resolve(r)
+			if err != nil {
+				slog.Default().InfoContext(
+					r.Context(), "failed to resolve the config",
+					requestAttrs(r, slog.String("reason", err.Error()))...,
+				)
+				GraphQLError(w, r, err)
+				return
+			}
+			r = SetConfig(r, conf)
+			if build == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			handler, ok := handlers.Load(conf)
+			if !ok {
+				handler, _ = handlers.LoadOrStore(conf, build(conf)(next))
+			}
+			handler.(http.Handler).ServeHTTP(w, r)
+		})
+	}
+}
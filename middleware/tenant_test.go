@@ -0,0 +1,78 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+
+	_conf "github.com/hiroaki-yamamoto/gauth/config"
+	"github.com/hiroaki-yamamoto/gauth/core"
+	mid "github.com/hiroaki-yamamoto/gauth/middleware"
+)
+
+// Multi-tenant middleware test
+
+func TestResolve(t *testing.T) {
+	resolver := _conf.HostResolver{}
+	for _, tenant := range []string{"acme", "initech"} {
+		host := tenant + ".example.com"
+		conf, err := _conf.New(
+			"session", _conf.Cookie, mustHS256("test"),
+			"Test Audience", tenant, "Test Subject",
+			time.Hour, _conf.CookieConfig{Path: "/", Domain: host},
+		)
+		assert.NilError(t, err)
+		conf.Tenant = tenant
+		resolver[host] = conf
+	}
+	login := mid.Resolve(resolver, nil)(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			conf := mid.GetConfig(r.Context())
+			user := User{UserBase{Username: "test"}}
+			assert.NilError(t, core.Login(w, conf, user))
+		},
+	))
+	private := mid.Resolve(
+		resolver, func(conf *_conf.Config) func(http.Handler) http.Handler {
+			return mid.LoginRequired(Con{}, findTestUser, conf)
+		},
+	)(handlerFunc)
+	serve := func(
+		handler http.Handler, host string, cookie *http.Cookie,
+	) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Host = host
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve(login, "acme.example.com", nil)
+	cookies := rec.Result().Cookies()
+	assert.Equal(t, len(cookies), 1)
+	assert.Equal(t, cookies[0].Domain, "acme.example.com")
+	jot, err := core.ExtractAccess(
+		cookies[0].Value, resolver["acme.example.com"],
+	)
+	assert.NilError(t, err)
+	assert.Equal(t, jot.Claims.Issuer, "acme")
+
+	t.Run("Own tenant", func(t *testing.T) {
+		rec := serve(private, "acme.example.com", cookies[0])
+		assert.Equal(t, rec.Code, http.StatusOK)
+	})
+	t.Run("Another tenant", func(t *testing.T) {
+		rec := serve(private, "initech.example.com", cookies[0])
+		assert.Equal(t, rec.Code, http.StatusUnauthorized)
+	})
+	t.Run("Unknown tenant", func(t *testing.T) {
+		rec := serve(private, "evil.example.com", cookies[0])
+		assert.Equal(t, rec.Code, http.StatusUnauthorized)
+	})
+}
@@ -0,0 +1,59 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+
+	_conf "github.com/hiroaki-yamamoto/gauth/config"
+	"github.com/hiroaki-yamamoto/gauth/core"
+	mid "github.com/hiroaki-yamamoto/gauth/middleware"
+	"github.com/hiroaki-yamamoto/gauth/store"
+)
+
+// Token version test
+
+type VersionedUser struct {
+	User
+	Version int64
+}
+
+func (me VersionedUser) GetTokenVersion() int64 {
+	return me.Version
+}
+
+func TestTokenVersion(t *testing.T) {
+	conf, err := _conf.New(
+		"session", _conf.Header, mustHS256("test"),
+		"Test Audience", "Test Issuer", "Test Subject",
+		time.Hour, _conf.CookieConfig{},
+	)
+	assert.NilError(t, err)
+	conf.RefreshStore = store.NewMemoryRefreshStore()
+	user := VersionedUser{User{UserBase{Username: "test_username"}}, 1}
+	findUser := func(con interface{}, ID string) (interface{}, error) {
+		return user, nil
+	}
+	pair, err := core.ComposeUserPair(user, conf)
+	assert.NilError(t, err)
+
+	t.Run("Current", func(t *testing.T) {
+		_, err := mid.JwtToUser(string(pair.Access), findUser, nil, conf)
+		assert.NilError(t, err)
+	})
+	t.Run("Stale", func(t *testing.T) {
+		user.Version = 2
+		defer func() { user.Version = 1 }()
+		_, err := mid.JwtToUser(string(pair.Access), findUser, nil, conf)
+		assert.ErrorIs(t, err, core.ErrStaleToken)
+
+		req := httptest.NewRequest("POST", "/refresh", nil)
+		req.Header.Set(conf.RefreshSessionName(), string(pair.Refresh))
+		rec := httptest.NewRecorder()
+		mid.RefreshHandler(nil, findUser, conf).ServeHTTP(rec, req)
+		assert.Equal(t, rec.Code, http.StatusUnauthorized)
+	})
+}
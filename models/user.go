@@ -6,3 +6,23 @@ package models
 type IUser interface {
 	GetID() string // Should return the ID or username of the user.
 }
+
+// TokenVersioner is an optional interface of IUser. The token version is
+// embedded into the tokens of the user, and the tokens that were issued for
+// an older version are rejected. Increment the version to invalidate all
+// the tokens of the user, e.g. when the password is changed.
+type TokenVersioner interface {
+	GetTokenVersion() int64
+}
+
+// RoleHolder is an optional interface of IUser that RequireRoles middleware
+// checks.
+type RoleHolder interface {
+	GetRoles() []string
+}
+
+// PermissionHolder is an optional interface of IUser that
+// RequirePermissions middleware checks.
+type PermissionHolder interface {
+	GetPermissions() []string
+}
@@ -0,0 +1,208 @@
+package policy
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Conditions of the rules
+
+// Condition is the condition of a rule. Exactly one of All, Any, Not, Func
+// and Attr must be set, and Attr must be compared with exactly one of the
+// other fields.
+type Condition struct {
+	// All holds if all of the conditions hold.
+	All []*Condition `json:"all,omitempty" yaml:"all,omitempty"`
+	// Any holds if any of the conditions holds.
+	Any []*Condition `json:"any,omitempty" yaml:"any,omitempty"`
+	// Not holds if the condition doesn't hold.
+	Not *Condition `json:"not,omitempty" yaml:"not,omitempty"`
+	// Func holds if it returns true. This can only be set in Go.
+	Func func(env Env) bool `json:"-" yaml:"-"`
+
+	// Attr is the path of the attribute to compare, e.g. "user.id". See
+	// Attributes for the available attributes.
+	Attr string `json:"attr,omitempty" yaml:"attr,omitempty"`
+	// Equals holds if the attribute is equal to the value.
+	Equals any `json:"equals,omitempty" yaml:"equals,omitempty"`
+	// EqualsAttr holds if the attribute is equal to the other attribute.
+	EqualsAttr string `json:"equals_attr,omitempty" yaml:"equals_attr,omitempty"`
+	// In holds if the attribute is one of the values.
+	In []any `json:"in,omitempty" yaml:"in,omitempty"`
+	// Contains holds if the attribute is a list that contains the value.
+	Contains any `json:"contains,omitempty" yaml:"contains,omitempty"`
+	// ContainsAttr holds if the attribute is a list that contains the value
+	// of the other attribute.
+	ContainsAttr string `json:"contains_attr,omitempty" yaml:"contains_attr,omitempty"`
+}
+
+func (me *Condition) validate() error {
+	operators := 0
+	for _, set := range []bool{
+		me.All != nil, me.Any != nil, me.Not != nil, me.Func != nil,
+		me.Attr != "",
+	} {
+		if set {
+			operators++
+		}
+	}
+	if operators != 1 {
+		return fmt.Errorf("%w: exactly one operator is required", errCondition)
+	}
+	if (me.All != nil && len(me.All) == 0) ||
+		(me.Any != nil && len(me.Any) == 0) {
+		return fmt.Errorf("%w: all / any needs a condition", errCondition)
+	}
+	if me.Attr != "" {
+		comparisons := 0
+		for _, set := range []bool{
+			me.Equals != nil, me.EqualsAttr != "", me.In != nil,
+			me.Contains != nil, me.ContainsAttr != "",
+		} {
+			if set {
+				comparisons++
+			}
+		}
+		if comparisons != 1 {
+			return fmt.Errorf(
+				"%w: %s must be compared with exactly one value",
+				errCondition, me.Attr,
+			)
+		}
+	}
+	for _, cond := range append(me.All, me.Any...) {
+		if cond == nil {
+			return fmt.Errorf("%w: all / any has a null condition", errCondition)
+		}
+		if err := cond.validate(); err != nil {
+			return err
+		}
+	}
+	if me.Not != nil {
+		return me.Not.validate()
+	}
+	return nil
+}
+
+// eval evaluates the condition on env. The empty string is returned if the
+// condition holds; otherwise, the reason why it doesn't hold is returned.
+// The nil condition, i.e. the rule without When, always holds, but the nil
+// conditions in All and Any never hold, nor do empty All and Any, so that
+// the malformed conditions built in Go don't allow everything.
+func (me *Condition) eval(env Env) string {
+	switch {
+	case me == nil:
+		return ""
+	case me.All != nil:
+		if len(me.All) == 0 {
+			return "no condition in all"
+		}
+		for _, cond := range me.All {
+			if cond == nil {
+				return "null condition in all"
+			}
+			if reason := cond.eval(env); reason != "" {
+				return reason
+			}
+		}
+		return ""
+	case me.Any != nil:
+		if len(me.Any) == 0 {
+			return "no condition in any"
+		}
+		reasons := make([]string, 0, len(me.Any))
+		for _, cond := range me.Any {
+			if cond == nil {
+				reasons = append(reasons, "null condition in any")
+				continue
+			}
+			reason := cond.eval(env)
+			if reason == "" {
+				return ""
+			}
+			reasons = append(reasons, reason)
+		}
+		return strings.Join(reasons, " and ")
+	case me.Not != nil:
+		if me.Not.eval(env) == "" {
+			return "negated condition holds"
+		}
+		return ""
+	case me.Func != nil:
+		if !me.Func(env) {
+			return "function condition doesn't hold"
+		}
+		return ""
+	}
+	value, _ := env.Lookup(me.Attr)
+	switch {
+	case me.EqualsAttr != "":
+		other, _ := env.Lookup(me.EqualsAttr)
+		if !equal(value, other) {
+			return fmt.Sprintf("%s != %s", me.Attr, me.EqualsAttr)
+		}
+	case me.In != nil:
+		if !contains(me.In, value) {
+			return fmt.Sprintf("%s is not in %v", me.Attr, me.In)
+		}
+	case me.Contains != nil:
+		if !contains(value, me.Contains) {
+			return fmt.Sprintf("%s doesn't contain %v", me.Attr, me.Contains)
+		}
+	case me.ContainsAttr != "":
+		other, _ := env.Lookup(me.ContainsAttr)
+		if !contains(value, other) {
+			return fmt.Sprintf("%s doesn't contain %s", me.Attr, me.ContainsAttr)
+		}
+	default:
+		if !equal(value, me.Equals) {
+			return fmt.Sprintf("%s != %v", me.Attr, me.Equals)
+		}
+	}
+	return ""
+}
+
+// equal compares a and b. The numbers are compared by their values
+// regardless of their types, because JSON decodes them as float64 while
+// YAML decodes them as int.
+func equal(a, b any) bool {
+	if a == nil || b == nil {
+		return false
+	}
+	if x, ok := toFloat(a); ok {
+		y, ok := toFloat(b)
+		return ok && x == y
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func toFloat(v any) (float64, bool) {
+	value := reflect.ValueOf(v)
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64:
+		return float64(value.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), true
+	default:
+		return 0, false
+	}
+}
+
+// contains returns true if list is a slice that has an element equal to
+// value.
+func contains(list, value any) bool {
+	items := reflect.ValueOf(list)
+	if items.Kind() != reflect.Slice && items.Kind() != reflect.Array {
+		return false
+	}
+	for i := range items.Len() {
+		if equal(items.Index(i).Interface(), value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"strings"
+
+	"github.com/hiroaki-yamamoto/gauth/middleware"
+	"github.com/hiroaki-yamamoto/gauth/models"
+)
+
+// Attributes of the authorization
+
+// Env is the attributes that the conditions are evaluated on.
+type Env map[string]any
+
+// Lookup returns the attribute at the dot-separated path, e.g.
+// "resource.owner_id".
+func (me Env) Lookup(path string) (any, bool) {
+	var value any = map[string]any(me)
+	for _, key := range strings.Split(path, ".") {
+		attrs, ok := value.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if value, ok = attrs[key]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// Attributer is an optional interface of the user that provides the
+// attributes for the policy, e.g. {"tenant_id": "acme"}.
+type Attributer interface {
+	PolicyAttributes() map[string]any
+}
+
+type requestKey struct{}
+
+// WithRequest returns the copy of ctx that holds r, so that Authorize can
+// refer to the attributes of r. The middleware of Policy does this.
+func WithRequest(ctx context.Context, r *http.Request) context.Context {
+	return context.WithValue(ctx, requestKey{}, r)
+}
+
+// Attributes returns the attributes of action on resource by the user of
+// ctx. They are:
+//
+//   - "action": action.
+//   - "user": "id", "roles", "permissions" of models.RoleHolder and
+//     models.PermissionHolder, and the attributes of Attributer.
+//   - "claims": the custom claims of the token, "scope" and "sid". See
+//     middleware.GetCustomClaims.
+//   - "request": "method", "path", "host", "remote_addr" and "header"
+//     of the request set by WithRequest. The header names are canonical,
+//     e.g. "request.header.X-Tenant".
+//   - "resource": "type", "id" and Resource.Attrs.
+func Attributes(ctx context.Context, action string, resource Resource) Env {
+	env := Env{"action": action}
+	if user, ok := middleware.GetUser(ctx).(models.IUser); ok {
+		env["user"] = userAttrs(user)
+	}
+	env["claims"] = claimsAttrs(ctx)
+	if r, ok := ctx.Value(requestKey{}).(*http.Request); ok {
+		env["request"] = requestAttrs(r)
+	}
+	attrs := maps.Clone(resource.Attrs)
+	if attrs == nil {
+		attrs = map[string]any{}
+	}
+	attrs["type"], attrs["id"] = resource.Type, resource.ID
+	env["resource"] = attrs
+	return env
+}
+
+func userAttrs(user models.IUser) map[string]any {
+	attrs := map[string]any{}
+	if attributer, ok := user.(Attributer); ok {
+		maps.Copy(attrs, attributer.PolicyAttributes())
+	}
+	attrs["id"] = user.GetID()
+	if holder, ok := user.(models.RoleHolder); ok {
+		attrs["roles"] = holder.GetRoles()
+	}
+	if holder, ok := user.(models.PermissionHolder); ok {
+		attrs["permissions"] = holder.GetPermissions()
+	}
+	return attrs
+}
+
+// claimsAttrs returns the claims of ctx. The custom claims are converted
+// to a map through JSON so that they are referred by their claim names.
+func claimsAttrs(ctx context.Context) map[string]any {
+	attrs := map[string]any{}
+	if claims := middleware.GetCustomClaims(ctx); claims != nil {
+		if data, err := json.Marshal(claims); err == nil {
+			json.Unmarshal(data, &attrs)
+		}
+	}
+	if scopes := middleware.GetScopes(ctx); scopes != nil {
+		attrs["scope"] = scopes
+	}
+	if sid := middleware.GetSessionID(ctx); sid != "" {
+		attrs["sid"] = sid
+	}
+	return attrs
+}
+
+func requestAttrs(r *http.Request) map[string]any {
+	header := make(map[string]any, len(r.Header))
+	for name := range r.Header {
+		header[name] = r.Header.Get(name)
+	}
+	return map[string]any{
+		"method":      r.Method,
+		"path":        r.URL.Path,
+		"host":        r.Host,
+		"remote_addr": r.RemoteAddr,
+		"header":      header,
+	}
+}
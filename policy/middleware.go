@@ -0,0 +1,44 @@
+package policy
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/hiroaki-yamamoto/gauth/config"
+	"github.com/hiroaki-yamamoto/gauth/middleware"
+	"github.com/hiroaki-yamamoto/gauth/models"
+)
+
+// Policy middleware
+
+// ResourceFunc resolves the resource of the request, e.g. loads the order
+// identified by the path.
+type ResourceFunc func(r *http.Request) (Resource, error)
+
+// Middleware returns a middleware that authorizes action on the resource
+// that resolve returns. Like middleware.RequireRoles, it must be placed
+// after middleware.LoginRequired. The denied requests are responded with
+// 403 (Forbidden) and DeniedError. If resolve fails, the request is
+// denied as well.
+func (me *Policy) Middleware(
+	conf *config.Config,
+	action string,
+	resolve ResourceFunc,
+) func(http.Handler) http.Handler {
+	return middleware.Require(
+		conf, func(r *http.Request, _ models.IUser) error {
+			resource, err := resolve(r)
+			if err != nil {
+				return fmt.Errorf("%w: %w", middleware.ErrForbidden, err)
+			}
+			return me.Authorize(WithRequest(r.Context(), r), action, resource)
+		},
+	)
+}
+
+// Static returns a ResourceFunc that always returns resource.
+func Static(resource Resource) ResourceFunc {
+	return func(*http.Request) (Resource, error) {
+		return resource, nil
+	}
+}
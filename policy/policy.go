@@ -0,0 +1,265 @@
+// Package policy provides an attribute-based authorization engine. A Policy
+// is a list of declarative rules over the attributes of the user, the token
+// claims, the request and the resource, and it decides whether the user may
+// perform an action on the resource.
+//
+// The rules can be loaded from JSON or YAML:
+//
+//	rules:
+//	  - name: owner
+//	    effect: allow
+//	    actions: [edit]
+//	    resources: [order]
+//	    when: {attr: resource.owner_id, equals_attr: user.id}
+//	  - name: tenant-admin
+//	    effect: allow
+//	    actions: [edit]
+//	    resources: [order]
+//	    when:
+//	      all:
+//	        - {attr: user.roles, contains: admin}
+//	        - {attr: user.tenant_id, equals_attr: resource.tenant_id}
+//
+// or built in Go with the same types. A deny rule overrides the allow rules,
+// and the action is denied if no rule matches.
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/hiroaki-yamamoto/gauth/middleware"
+	"gopkg.in/yaml.v3"
+)
+
+// Effect is the effect of a rule.
+type Effect string
+
+const (
+	// Allow allows the action.
+	Allow Effect = "allow"
+	// Deny denies the action even if the other rules allow it.
+	Deny Effect = "deny"
+)
+
+// Rule is a rule of Policy.
+type Rule struct {
+	// Name identifies the rule in Decision.
+	Name string `json:"name" yaml:"name"`
+	// Effect is the effect of the rule when it matches.
+	Effect Effect `json:"effect" yaml:"effect"`
+	// Actions is the actions that the rule applies to. "*" matches any
+	// action, and the empty list matches any action as well.
+	Actions []string `json:"actions,omitempty" yaml:"actions,omitempty"`
+	// Resources is the types of the resources that the rule applies to.
+	// "*" matches any type, and the empty list matches any type as well.
+	Resources []string `json:"resources,omitempty" yaml:"resources,omitempty"`
+	// When is the condition of the rule. If this is nil, the rule matches
+	// whenever it applies to the action and the resource.
+	When *Condition `json:"when,omitempty" yaml:"when,omitempty"`
+}
+
+// appliesTo returns true if the rule applies to action on resource.
+func (me *Rule) appliesTo(action string, resource Resource) bool {
+	return matchesAny(me.Actions, action) &&
+		matchesAny(me.Resources, resource.Type)
+}
+
+func matchesAny(patterns []string, value string) bool {
+	return len(patterns) == 0 || slices.Contains(patterns, "*") ||
+		slices.Contains(patterns, value)
+}
+
+// Policy is a set of rules.
+type Policy struct {
+	Rules []Rule `json:"rules" yaml:"rules"`
+}
+
+// ParseJSON parses the policy from JSON, and validates it.
+func ParseJSON(data []byte) (*Policy, error) {
+	var policy Policy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, err
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// ParseYAML parses the policy from YAML, and validates it.
+func ParseYAML(data []byte) (*Policy, error) {
+	var policy Policy
+	if err := yaml.Unmarshal(data, &policy); err != nil {
+		return nil, err
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// Validate checks that the effects and the conditions of the rules are
+// well-formed.
+func (me *Policy) Validate() error {
+	for i, rule := range me.Rules {
+		name := rule.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i)
+		}
+		if rule.Effect != Allow && rule.Effect != Deny {
+			return fmt.Errorf("rule %s: unknown effect %q", name, rule.Effect)
+		}
+		if rule.When == nil {
+			continue
+		}
+		if err := rule.When.validate(); err != nil {
+			return fmt.Errorf("rule %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Resource is the resource that an action is performed on.
+type Resource struct {
+	// Type is the type of the resource, e.g. "order".
+	Type string
+	// ID is the ID of the resource.
+	ID string
+	// Attrs is the attributes of the resource, e.g. the owner of an order.
+	Attrs map[string]any
+}
+
+// Step is the result of a rule in Decision.Trace.
+type Step struct {
+	// Rule is the name of the rule.
+	Rule string
+	// Effect is the effect of the rule.
+	Effect Effect
+	// Matched is true if the rule applies to the action and the resource,
+	// and its condition holds.
+	Matched bool
+	// Reason tells why the rule didn't match.
+	Reason string
+}
+
+// Decision is the result of the authorization.
+type Decision struct {
+	// Allowed is true if the action is allowed.
+	Allowed bool
+	// Rule is the name of the rule that decided. It is empty if no rule
+	// matched.
+	Rule string
+	// Trace is the results of all the rules in order. It is set only by
+	// Explain.
+	Trace []Step
+}
+
+// String describes the decision.
+func (me *Decision) String() string {
+	switch {
+	case me.Rule == "":
+		return "denied: no rule matched"
+	case me.Allowed:
+		return fmt.Sprintf("allowed by rule %s", me.Rule)
+	default:
+		return fmt.Sprintf("denied by rule %s", me.Rule)
+	}
+}
+
+// DeniedError is returned by Authorize when the action is denied. It wraps
+// middleware.ErrForbidden so that the middleware responds with 403
+// (Forbidden).
+type DeniedError struct {
+	Action   string
+	Resource Resource
+	Decision *Decision
+}
+
+func (me *DeniedError) Error() string {
+	target := me.Resource.Type
+	if me.Resource.ID != "" {
+		target += " " + me.Resource.ID
+	}
+	return fmt.Sprintf(
+		"%s %s on %s: %s", middleware.ErrForbidden, me.Action, target,
+		me.Decision,
+	)
+}
+
+// Unwrap returns middleware.ErrForbidden.
+func (me *DeniedError) Unwrap() error {
+	return middleware.ErrForbidden
+}
+
+// Authorize returns nil if the user of ctx may perform action on resource.
+// Otherwise, DeniedError is returned. The user, the claims and the
+// request are taken from ctx as Attributes describes.
+func (me *Policy) Authorize(
+	ctx context.Context,
+	action string,
+	resource Resource,
+) error {
+	env := Attributes(ctx, action, resource)
+	decision := me.decide(env, action, resource, false)
+	if !decision.Allowed {
+		return &DeniedError{
+			Action: action, Resource: resource, Decision: decision,
+		}
+	}
+	return nil
+}
+
+// Explain is the same as Authorize, but it returns the decision with the
+// trace of all the rules, so that the reason of the decision can be seen.
+func (me *Policy) Explain(
+	ctx context.Context,
+	action string,
+	resource Resource,
+) *Decision {
+	return me.decide(Attributes(ctx, action, resource), action, resource, true)
+}
+
+// decide evaluates the rules on env. All the rules are evaluated when trace
+// is true; otherwise, the evaluation stops at the first deny rule that
+// matches.
+func (me *Policy) decide(
+	env Env,
+	action string,
+	resource Resource,
+	trace bool,
+) *Decision {
+	decision := &Decision{}
+	denied := false
+	for _, rule := range me.Rules {
+		step := Step{Rule: rule.Name, Effect: rule.Effect}
+		if !rule.appliesTo(action, resource) {
+			step.Reason = "not applicable"
+		} else if reason := rule.When.eval(env); reason != "" {
+			step.Reason = reason
+		} else {
+			step.Matched = true
+		}
+		if trace {
+			decision.Trace = append(decision.Trace, step)
+		}
+		if !step.Matched || denied {
+			continue
+		}
+		if rule.Effect == Deny {
+			decision.Allowed, decision.Rule, denied = false, rule.Name, true
+			if !trace {
+				break
+			}
+		} else if !decision.Allowed {
+			decision.Allowed, decision.Rule = true, rule.Name
+		}
+	}
+	return decision
+}
+
+// errCondition is returned by Validate for the malformed conditions.
+var errCondition = errors.New("malformed condition")
@@ -0,0 +1,240 @@
+package policy_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"codeberg.org/gbrlsnchs/jwt"
+	"gotest.tools/v3/assert"
+
+	_conf "github.com/hiroaki-yamamoto/gauth/config"
+	"github.com/hiroaki-yamamoto/gauth/core"
+	mid "github.com/hiroaki-yamamoto/gauth/middleware"
+	"github.com/hiroaki-yamamoto/gauth/policy"
+)
+
+// Policy engine test
+
+type User struct {
+	ID     string
+	Tenant string
+	Roles  []string
+}
+
+func (me User) GetID() string {
+	return me.ID
+}
+
+func (me User) GetRoles() []string {
+	return me.Roles
+}
+
+func (me User) PolicyAttributes() map[string]any {
+	return map[string]any{"tenant_id": me.Tenant}
+}
+
+const orderPolicy = `
+rules:
+  - name: owner
+    effect: allow
+    actions: [edit]
+    resources: [order]
+    when: {attr: resource.owner_id, equals_attr: user.id}
+  - name: tenant-admin
+    effect: allow
+    actions: [edit]
+    resources: [order]
+    when:
+      all:
+        - {attr: user.roles, contains: admin}
+        - {attr: user.tenant_id, equals_attr: resource.tenant_id}
+  - name: locked
+    effect: deny
+    actions: ["*"]
+    when: {attr: resource.locked, equals: true}
+`
+
+func order(owner, tenant string, locked bool) policy.Resource {
+	return policy.Resource{Type: "order", ID: "1", Attrs: map[string]any{
+		"owner_id": owner, "tenant_id": tenant, "locked": locked,
+	}}
+}
+
+func userContext(user User) context.Context {
+	req := httptest.NewRequest("GET", "/", nil)
+	return mid.SetUser(req, user).Context()
+}
+
+func TestAuthorize(t *testing.T) {
+	pol, err := policy.ParseYAML([]byte(orderPolicy))
+	assert.NilError(t, err)
+	alice := userContext(User{ID: "alice", Tenant: "acme"})
+	admin := userContext(
+		User{ID: "bob", Tenant: "acme", Roles: []string{"admin"}},
+	)
+
+	t.Run("Owner", func(t *testing.T) {
+		err := pol.Authorize(alice, "edit", order("alice", "acme", false))
+		assert.NilError(t, err)
+	})
+	t.Run("Tenant admin", func(t *testing.T) {
+		err := pol.Authorize(admin, "edit", order("alice", "acme", false))
+		assert.NilError(t, err)
+	})
+	t.Run("Admin of another tenant", func(t *testing.T) {
+		err := pol.Authorize(admin, "edit", order("carol", "other", false))
+		assert.ErrorIs(t, err, mid.ErrForbidden)
+		var denied *policy.DeniedError
+		assert.Assert(t, errors.As(err, &denied))
+		assert.Equal(t, denied.Decision.Rule, "")
+	})
+	t.Run("Deny overrides", func(t *testing.T) {
+		err := pol.Authorize(alice, "edit", order("alice", "acme", true))
+		assert.Error(t, err, "forbidden edit on order 1: denied by rule locked")
+	})
+	t.Run("Other action", func(t *testing.T) {
+		err := pol.Authorize(alice, "delete", order("alice", "acme", false))
+		assert.ErrorIs(t, err, mid.ErrForbidden)
+	})
+	t.Run("Explain", func(t *testing.T) {
+		decision := pol.Explain(admin, "edit", order("alice", "acme", true))
+		assert.Assert(t, !decision.Allowed)
+		assert.Equal(t, decision.Rule, "locked")
+		assert.DeepEqual(t, decision.Trace, []policy.Step{
+			{
+				Rule: "owner", Effect: policy.Allow,
+				Reason: "resource.owner_id != user.id",
+			},
+			{Rule: "tenant-admin", Effect: policy.Allow, Matched: true},
+			{Rule: "locked", Effect: policy.Deny, Matched: true},
+		})
+	})
+}
+
+func TestMalformedCondition(t *testing.T) {
+	for name, when := range map[string]*policy.Condition{
+		"Empty any":   {Any: []*policy.Condition{}},
+		"Empty all":   {All: []*policy.Condition{}},
+		"Null in any": {Any: []*policy.Condition{nil}},
+		"Null in all": {All: []*policy.Condition{nil}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			pol := &policy.Policy{Rules: []policy.Rule{{
+				Name: "malformed", Effect: policy.Allow, When: when,
+			}}}
+			err := pol.Authorize(
+				context.Background(), "delete", policy.Resource{Type: "order"},
+			)
+			assert.ErrorIs(t, err, mid.ErrForbidden)
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	t.Run("JSON", func(t *testing.T) {
+		pol, err := policy.ParseJSON([]byte(`{"rules": [{
+			"name": "tenant", "effect": "allow",
+			"when": {"any": [
+				{"attr": "claims.tenant", "in": ["acme", "initech"]},
+				{"not": {"attr": "request.method", "equals": "GET"}}
+			]}
+		}]}`))
+		assert.NilError(t, err)
+		assert.Equal(t, len(pol.Rules), 1)
+		assert.Equal(t, len(pol.Rules[0].When.Any), 2)
+	})
+	t.Run("Null condition in JSON", func(t *testing.T) {
+		_, err := policy.ParseJSON([]byte(`{"rules": [{
+			"name": "null", "effect": "allow", "when": {"all": [null]}
+		}]}`))
+		assert.ErrorContains(t, err, "rule null: malformed condition")
+	})
+	t.Run("Unknown effect", func(t *testing.T) {
+		_, err := policy.ParseJSON([]byte(`{"rules": [{"effect": "maybe"}]}`))
+		assert.Error(t, err, `rule #0: unknown effect "maybe"`)
+	})
+	t.Run("Malformed condition", func(t *testing.T) {
+		_, err := policy.ParseYAML([]byte(`
+rules:
+  - name: broken
+    effect: allow
+    when: {attr: user.id, equals: alice, in: [bob]}
+`))
+		assert.ErrorContains(t, err, "rule broken: malformed condition")
+	})
+	for _, when := range []string{
+		"{any: []}", "{all: []}", "{any: [null]}", "{all: [null]}",
+	} {
+		t.Run("Malformed "+when, func(t *testing.T) {
+			_, err := policy.ParseYAML([]byte(`
+rules:
+  - name: empty
+    effect: allow
+    when: ` + when + `
+`))
+			assert.ErrorContains(t, err, "rule empty: malformed condition")
+		})
+	}
+}
+
+type TenantClaims struct {
+	Tenant string `json:"tenant"`
+}
+
+func TestMiddleware(t *testing.T) {
+	conf, err := _conf.New(
+		"session", _conf.Header, mustHS256("test"),
+		"Test Audience", "Test Issuer", "Test Subject",
+		time.Hour, _conf.CookieConfig{},
+	)
+	assert.NilError(t, err)
+	pol := &policy.Policy{Rules: []policy.Rule{{
+		Name: "tenant-writer", Effect: policy.Allow,
+		When: &policy.Condition{All: []*policy.Condition{
+			{Attr: "claims.tenant", EqualsAttr: "request.header.X-Tenant"},
+			{Func: func(env policy.Env) bool {
+				method, _ := env.Lookup("request.method")
+				return method == http.MethodPost
+			}},
+		}},
+	}}}
+	findUser := func(con interface{}, ID string) (interface{}, error) {
+		return User{ID: ID}, nil
+	}
+	handler := mid.LoginRequiredWithClaims[TenantClaims](nil, findUser, conf)(
+		pol.Middleware(conf, "create", policy.Static(policy.Resource{
+			Type: "order",
+		}))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})),
+	)
+	token, err := core.ComposeClaims(
+		"alice", TenantClaims{Tenant: "acme"}, conf,
+	)
+	assert.NilError(t, err)
+	serve := func(method, tenant string) int {
+		req := httptest.NewRequest(method, "/orders", nil)
+		req.Header.Set(conf.SessionName, string(token))
+		req.Header.Set("X-Tenant", tenant)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, serve("POST", "acme"), http.StatusOK)
+	assert.Equal(t, serve("POST", "initech"), http.StatusForbidden)
+	assert.Equal(t, serve("GET", "acme"), http.StatusForbidden)
+}
+
+func mustHS256(k string) jwt.Signer {
+	for len(k) < 32 {
+		k += "0"
+	}
+	s, err := jwt.NewHS256([]byte(k))
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
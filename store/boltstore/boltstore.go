@@ -0,0 +1,208 @@
+// Package boltstore provides the implementations of the stores that persist
+// the state to an embedded bbolt database file, so that the state survives
+// restarts without an external database.
+//
+// The database file can't be opened by multiple processes at once; use a
+// shared database for the deployments with multiple processes.
+package boltstore
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hiroaki-yamamoto/gauth/clock"
+	"github.com/hiroaki-yamamoto/gauth/store"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	sessionBucket = []byte("sessions")
+	// userBucket is the index of the sessions by the user. The keys are
+	// the user ID and the session ID joined by userKey.
+	userBucket = []byte("user_sessions")
+)
+
+// userKey returns the key of userBucket.
+func userKey(userID, ID string) []byte {
+	return []byte(userID + "\x00" + ID)
+}
+
+// SessionStore is an implementation of store.SessionStore backed by bbolt.
+// The expired sessions are not returned by Get, and they are removed by
+// Prune.
+type SessionStore struct {
+	// Clock is used to determine whether a session is expired.
+	Clock clock.Time
+	db    *bolt.DB
+}
+
+// OpenSessionStore opens the database file at path, creating it if it
+// doesn't exist.
+func OpenSessionStore(path string) (*SessionStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	st, err := NewSessionStore(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return st, nil
+}
+
+// NewSessionStore creates a new SessionStore on the opened database. The
+// sessions are kept in "sessions" bucket, and they are indexed by the user
+// in "user_sessions" bucket.
+func NewSessionStore(db *bolt.DB) (*SessionStore, error) {
+	err := db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(sessionBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(userBucket)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &SessionStore{Clock: clock.DefaultTime{}, db: db}, nil
+}
+
+// Close closes the database.
+func (me *SessionStore) Close() error {
+	return me.db.Close()
+}
+
+// Save implements store.SessionStore.
+func (me *SessionStore) Save(session *store.Session) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	return me.db.Update(func(tx *bolt.Tx) error {
+		if err := deleteSession(tx, session.ID); err != nil {
+			return err
+		}
+		key := userKey(session.UserID, session.ID)
+		if err := tx.Bucket(userBucket).Put(key, nil); err != nil {
+			return err
+		}
+		return tx.Bucket(sessionBucket).Put([]byte(session.ID), data)
+	})
+}
+
+// Get implements store.SessionStore.
+func (me *SessionStore) Get(ID string) (*store.Session, error) {
+	var session store.Session
+	err := me.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(sessionBucket).Get([]byte(ID))
+		if data == nil {
+			return store.ErrSessionNotFound
+		}
+		return decodeSession(data, &session)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !me.Clock.Now().Before(session.ExpireAt) {
+		return nil, store.ErrSessionNotFound
+	}
+	return &session, nil
+}
+
+// decodeSession decodes data into session. Data of the session is
+// initialized even if it was empty, so that it can be written to.
+func decodeSession(data []byte, session *store.Session) error {
+	if err := json.Unmarshal(data, session); err != nil {
+		return err
+	}
+	if session.Data == nil {
+		session.Data = map[string]string{}
+	}
+	return nil
+}
+
+// Delete implements store.SessionStore.
+func (me *SessionStore) Delete(ID string) error {
+	return me.db.Update(func(tx *bolt.Tx) error {
+		return deleteSession(tx, ID)
+	})
+}
+
+// deleteSession deletes the session identified by ID and its index.
+func deleteSession(tx *bolt.Tx, ID string) error {
+	sessions := tx.Bucket(sessionBucket)
+	data := sessions.Get([]byte(ID))
+	if data == nil {
+		return nil
+	}
+	var session store.Session
+	if err := json.Unmarshal(data, &session); err == nil {
+		err = tx.Bucket(userBucket).Delete(userKey(session.UserID, ID))
+		if err != nil {
+			return err
+		}
+	}
+	return sessions.Delete([]byte(ID))
+}
+
+// List implements store.SessionStore.
+func (me *SessionStore) List(userID string) ([]*store.Session, error) {
+	now := me.Clock.Now()
+	var sessions []*store.Session
+	err := me.db.View(func(tx *bolt.Tx) error {
+		prefix := userKey(userID, "")
+		cursor := tx.Bucket(userBucket).Cursor()
+		for key, _ := cursor.Seek(prefix); bytes.HasPrefix(key, prefix); {
+			ID := key[len(prefix):]
+			key, _ = cursor.Next()
+			data := tx.Bucket(sessionBucket).Get(ID)
+			if data == nil {
+				continue
+			}
+			var session store.Session
+			if err := decodeSession(data, &session); err != nil {
+				return err
+			}
+			if session.UserID == userID && now.Before(session.ExpireAt) {
+				sessions = append(sessions, &session)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(sessions, func(a, b *store.Session) int {
+		return cmp.Or(
+			a.CreatedAt.Compare(b.CreatedAt), strings.Compare(a.ID, b.ID),
+		)
+	})
+	return sessions, nil
+}
+
+// Prune removes the expired sessions.
+func (me *SessionStore) Prune() error {
+	now := me.Clock.Now()
+	return me.db.Update(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(sessionBucket).Cursor()
+		for key, data := cursor.First(); key != nil; {
+			var session store.Session
+			err := json.Unmarshal(data, &session)
+			if err == nil && now.Before(session.ExpireAt) {
+				key, data = cursor.Next()
+				continue
+			}
+			deleted := append([]byte(nil), key...)
+			if err = cursor.Delete(); err != nil {
+				return err
+			}
+			key, data = cursor.Seek(deleted)
+		}
+		return nil
+	})
+}
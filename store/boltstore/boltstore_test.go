@@ -0,0 +1,103 @@
+package boltstore_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hiroaki-yamamoto/gauth/store"
+	"github.com/hiroaki-yamamoto/gauth/store/boltstore"
+	"gotest.tools/v3/assert"
+)
+
+// bbolt session store test
+
+type TimeMock struct {
+	Time time.Time
+}
+
+func (me TimeMock) Now() time.Time {
+	return me.Time
+}
+
+func TestSessionStore(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	path := filepath.Join(t.TempDir(), "sessions.db")
+	st, err := boltstore.OpenSessionStore(path)
+	assert.NilError(t, err)
+	st.Clock = TimeMock{now}
+	session := &store.Session{
+		ID: "session", UserID: "user", CreatedAt: now,
+		ExpireAt: now.Add(time.Hour),
+		Data:     map[string]string{"theme": "dark"},
+	}
+	assert.NilError(t, st.Save(session))
+	assert.NilError(t, st.Save(&store.Session{ID: "expired", ExpireAt: now}))
+
+	t.Run("Get", func(t *testing.T) {
+		got, err := st.Get("session")
+		assert.NilError(t, err)
+		assert.DeepEqual(t, got, session)
+	})
+	t.Run("Empty data", func(t *testing.T) {
+		assert.NilError(t, st.Save(&store.Session{
+			ID: "empty", UserID: "other", ExpireAt: now.Add(time.Hour),
+		}))
+		got, err := st.Get("empty")
+		assert.NilError(t, err)
+		got.Data["theme"] = "dark"
+		sessions, err := st.List("other")
+		assert.NilError(t, err)
+		assert.DeepEqual(t, sessions[0].Data, map[string]string{})
+	})
+	t.Run("Expired", func(t *testing.T) {
+		_, err := st.Get("expired")
+		assert.ErrorIs(t, err, store.ErrSessionNotFound)
+	})
+	t.Run("Reopen", func(t *testing.T) {
+		assert.NilError(t, st.Close())
+		st, err = boltstore.OpenSessionStore(path)
+		assert.NilError(t, err)
+		st.Clock = TimeMock{now}
+		got, err := st.Get("session")
+		assert.NilError(t, err)
+		assert.DeepEqual(t, got, session)
+	})
+	t.Run("Prune", func(t *testing.T) {
+		assert.NilError(t, st.Prune())
+		st.Clock = TimeMock{now.Add(-time.Hour)}
+		_, err := st.Get("expired")
+		assert.ErrorIs(t, err, store.ErrSessionNotFound)
+		_, err = st.Get("session")
+		assert.NilError(t, err)
+	})
+	t.Run("List", func(t *testing.T) {
+		older := &store.Session{
+			ID: "older", UserID: "user", CreatedAt: now.Add(-time.Minute),
+			ExpireAt: now.Add(time.Hour), Data: map[string]string{},
+		}
+		assert.NilError(t, st.Save(older))
+		// Created at the same time as session, and listed before it by ID.
+		another := &store.Session{
+			ID: "another", UserID: "user", CreatedAt: now,
+			ExpireAt: now.Add(time.Hour), Data: map[string]string{},
+		}
+		assert.NilError(t, st.Save(another))
+		assert.NilError(t, st.Save(&store.Session{
+			ID: "other", UserID: "other", ExpireAt: now.Add(time.Hour),
+		}))
+		sessions, err := st.List("user")
+		assert.NilError(t, err)
+		assert.DeepEqual(t, sessions, []*store.Session{older, another, session})
+		assert.NilError(t, st.Delete("another"))
+	})
+	t.Run("Delete", func(t *testing.T) {
+		assert.NilError(t, st.Delete("session"))
+		_, err := st.Get("session")
+		assert.ErrorIs(t, err, store.ErrSessionNotFound)
+		sessions, err := st.List("user")
+		assert.NilError(t, err)
+		assert.Equal(t, len(sessions), 1)
+	})
+	assert.NilError(t, st.Close())
+}
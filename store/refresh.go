@@ -0,0 +1,128 @@
+package store
+
+// Refresh token family store
+
+import (
+	"sync"
+	"time"
+
+	"github.com/hiroaki-yamamoto/gauth/clock"
+)
+
+// RefreshStore keeps track of the latest refresh token of each token family.
+// A family is the chain of refresh tokens that descends from a single login;
+// only its latest member may be exchanged for a new token pair.
+type RefreshStore interface {
+	// Issue registers jti as the first refresh token of a new family.
+	Issue(family, jti string, expireAt time.Time) error
+	// Rotate replaces the latest refresh token of the family from oldJTI to
+	// newJTI. If oldJTI is not the latest one, the family must be discarded
+	// and ErrReused must be returned. If the family doesn't exist,
+	// ErrUnknownFamily must be returned.
+	Rotate(family, oldJTI, newJTI string, expireAt time.Time) error
+	// RevokeFamily discards the family so that none of its refresh tokens
+	// can be used anymore.
+	RevokeFamily(family string) error
+}
+
+type refreshEntry struct {
+	jti      string
+	expireAt time.Time
+}
+
+// MemoryRefreshStore is an in-memory implementation of RefreshStore. The
+// families are pruned after their latest refresh tokens expire. It is safe
+// for concurrent use, but the families are lost on restart and are not
+// shared between processes.
+type MemoryRefreshStore struct {
+	// Clock is used to determine whether a family is expired.
+	Clock clock.Time
+	// PruneInterval is the minimum interval between the prunings that
+	// Issue performs. If this is zero, DefaultPruneInterval is used.
+	PruneInterval time.Duration
+	mutex         sync.Mutex
+	families      map[string]refreshEntry
+	nextPrune     time.Time
+}
+
+// NewMemoryRefreshStore creates a new empty MemoryRefreshStore.
+func NewMemoryRefreshStore() *MemoryRefreshStore {
+	return &MemoryRefreshStore{
+		Clock:    clock.DefaultTime{},
+		families: map[string]refreshEntry{},
+	}
+}
+
+// Issue implements RefreshStore. It also prunes the expired families when
+// PruneInterval has passed since the last pruning.
+func (me *MemoryRefreshStore) Issue(
+	family, jti string, expireAt time.Time,
+) error {
+	now := me.Clock.Now()
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	if !now.Before(me.nextPrune) {
+		me.prune(now)
+	}
+	me.families[family] = refreshEntry{jti, expireAt}
+	return nil
+}
+
+// Rotate implements RefreshStore.
+func (me *MemoryRefreshStore) Rotate(
+	family, oldJTI, newJTI string, expireAt time.Time,
+) error {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	entry, ok := me.families[family]
+	if !ok {
+		return ErrUnknownFamily
+	}
+	if !me.Clock.Now().Before(entry.expireAt) {
+		delete(me.families, family)
+		return ErrUnknownFamily
+	}
+	if entry.jti != oldJTI {
+		delete(me.families, family)
+		return ErrReused
+	}
+	me.families[family] = refreshEntry{newJTI, expireAt}
+	return nil
+}
+
+// RevokeFamily implements RefreshStore.
+func (me *MemoryRefreshStore) RevokeFamily(family string) error {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	delete(me.families, family)
+	return nil
+}
+
+// Prune discards the families whose latest refresh tokens are expired.
+func (me *MemoryRefreshStore) Prune() {
+	now := me.Clock.Now()
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	me.prune(now)
+}
+
+// Len returns the number of the families including the expired ones that
+// are not pruned yet.
+func (me *MemoryRefreshStore) Len() int {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	return len(me.families)
+}
+
+func (me *MemoryRefreshStore) prune(now time.Time) {
+	for family, entry := range me.families {
+		if !now.Before(entry.expireAt) {
+			delete(me.families, family)
+		}
+	}
+	interval := me.PruneInterval
+	if interval <= 0 {
+		interval = DefaultPruneInterval
+	}
+	me.nextPrune = now.Add(interval)
+}
@@ -0,0 +1,68 @@
+package store_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hiroaki-yamamoto/gauth/store"
+	"gotest.tools/v3/assert"
+)
+
+// Refresh token family store test
+
+type TimeMock struct {
+	Time time.Time
+}
+
+func (me TimeMock) Now() time.Time {
+	return me.Time
+}
+
+func TestMemoryRefreshStore(t *testing.T) {
+	now := time.Now().UTC()
+	st := store.NewMemoryRefreshStore()
+	st.Clock = TimeMock{now}
+	assert.NilError(t, st.Issue("family", "first", now.Add(time.Hour)))
+
+	t.Run("Rotate", func(t *testing.T) {
+		err := st.Rotate("family", "first", "second", now.Add(time.Hour))
+		assert.NilError(t, err)
+	})
+	t.Run("Unknown family", func(t *testing.T) {
+		err := st.Rotate("unknown", "first", "second", now.Add(time.Hour))
+		assert.ErrorIs(t, err, store.ErrUnknownFamily)
+	})
+	t.Run("Reuse", func(t *testing.T) {
+		err := st.Rotate("family", "first", "third", now.Add(time.Hour))
+		assert.ErrorIs(t, err, store.ErrReused)
+		err = st.Rotate("family", "second", "third", now.Add(time.Hour))
+		assert.ErrorIs(t, err, store.ErrUnknownFamily)
+	})
+	t.Run("Expired", func(t *testing.T) {
+		assert.NilError(t, st.Issue("expired", "first", now))
+		err := st.Rotate("expired", "first", "second", now.Add(time.Hour))
+		assert.ErrorIs(t, err, store.ErrUnknownFamily)
+	})
+	t.Run("Revoke", func(t *testing.T) {
+		assert.NilError(t, st.Issue("revoked", "first", now.Add(time.Hour)))
+		assert.NilError(t, st.RevokeFamily("revoked"))
+		err := st.Rotate("revoked", "first", "second", now.Add(time.Hour))
+		assert.ErrorIs(t, err, store.ErrUnknownFamily)
+	})
+	t.Run("Prune on issue after the interval", func(t *testing.T) {
+		st := store.NewMemoryRefreshStore()
+		st.Clock = TimeMock{now}
+		st.PruneInterval = time.Minute
+		assert.NilError(t, st.Issue("expired", "first", now))
+		assert.NilError(t, st.Issue("valid", "first", now.Add(time.Hour)))
+		assert.Equal(t, st.Len(), 2)
+
+		st.Clock = TimeMock{now.Add(2 * time.Minute)}
+		assert.NilError(t, st.Issue("another", "first", now.Add(time.Hour)))
+		assert.Equal(t, st.Len(), 2)
+
+		st.Clock = TimeMock{now.Add(2 * time.Hour)}
+		st.Prune()
+		assert.Equal(t, st.Len(), 0)
+	})
+}
@@ -0,0 +1,97 @@
+package store
+
+// Token revocation store
+
+import (
+	"sync"
+	"time"
+
+	"github.com/hiroaki-yamamoto/gauth/clock"
+)
+
+// Revoker is a denylist of tokens keyed on their "jti" claim.
+type Revoker interface {
+	// Revoke revokes the token identified by jti. expireAt is the time the
+	// token is rejected as expired, i.e. its expiration plus the leeway of
+	// Config; the entry may be discarded after this time but must be kept
+	// until then.
+	Revoke(jti string, expireAt time.Time) error
+	// IsRevoked returns true if the token identified by jti is revoked.
+	IsRevoked(jti string) (bool, error)
+}
+
+// DefaultPruneInterval is the interval of pruning that is used when
+// PruneInterval of MemoryRevoker or MemoryRefreshStore is zero.
+const DefaultPruneInterval = 10 * time.Minute
+
+// MemoryRevoker is an in-memory implementation of Revoker. The entries are
+// pruned after their tokens expire. It is safe for concurrent use, but the
+// entries are lost on restart and are not shared between processes.
+type MemoryRevoker struct {
+	// Clock is used to determine whether an entry is expired.
+	Clock clock.Time
+	// PruneInterval is the minimum interval between the prunings that
+	// Revoke performs.
+	PruneInterval time.Duration
+	mutex         sync.RWMutex
+	entries       map[string]time.Time
+	nextPrune     time.Time
+}
+
+// NewMemoryRevoker creates a new empty MemoryRevoker.
+func NewMemoryRevoker() *MemoryRevoker {
+	return &MemoryRevoker{
+		Clock:   clock.DefaultTime{},
+		entries: map[string]time.Time{},
+	}
+}
+
+// Revoke implements Revoker. It also prunes the expired entries when
+// PruneInterval has passed since the last pruning.
+func (me *MemoryRevoker) Revoke(jti string, expireAt time.Time) error {
+	now := me.Clock.Now()
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	if !now.Before(me.nextPrune) {
+		me.prune(now)
+	}
+	me.entries[jti] = expireAt
+	return nil
+}
+
+// IsRevoked implements Revoker.
+func (me *MemoryRevoker) IsRevoked(jti string) (bool, error) {
+	me.mutex.RLock()
+	defer me.mutex.RUnlock()
+	expireAt, ok := me.entries[jti]
+	return ok && me.Clock.Now().Before(expireAt), nil
+}
+
+// Prune discards the entries whose tokens are already expired.
+func (me *MemoryRevoker) Prune() {
+	now := me.Clock.Now()
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	me.prune(now)
+}
+
+// Len returns the number of the entries including the expired ones that
+// are not pruned yet.
+func (me *MemoryRevoker) Len() int {
+	me.mutex.RLock()
+	defer me.mutex.RUnlock()
+	return len(me.entries)
+}
+
+func (me *MemoryRevoker) prune(now time.Time) {
+	for jti, expireAt := range me.entries {
+		if !now.Before(expireAt) {
+			delete(me.entries, jti)
+		}
+	}
+	interval := me.PruneInterval
+	if interval <= 0 {
+		interval = DefaultPruneInterval
+	}
+	me.nextPrune = now.Add(interval)
+}
@@ -0,0 +1,43 @@
+package store_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hiroaki-yamamoto/gauth/store"
+	"gotest.tools/v3/assert"
+)
+
+// Revocation store test
+
+func TestMemoryRevoker(t *testing.T) {
+	now := time.Now().UTC()
+	revoker := store.NewMemoryRevoker()
+	revoker.Clock = TimeMock{now}
+	revoker.PruneInterval = time.Minute
+
+	assert.NilError(t, revoker.Revoke("expired", now))
+	assert.NilError(t, revoker.Revoke("valid", now.Add(time.Hour)))
+
+	revoked, err := revoker.IsRevoked("valid")
+	assert.NilError(t, err)
+	assert.Assert(t, revoked)
+	revoked, err = revoker.IsRevoked("expired")
+	assert.NilError(t, err)
+	assert.Assert(t, !revoked)
+	revoked, err = revoker.IsRevoked("unknown")
+	assert.NilError(t, err)
+	assert.Assert(t, !revoked)
+
+	t.Run("Prune on revoke after the interval", func(t *testing.T) {
+		assert.Equal(t, revoker.Len(), 2)
+		revoker.Clock = TimeMock{now.Add(2 * time.Minute)}
+		assert.NilError(t, revoker.Revoke("another", now.Add(time.Hour)))
+		assert.Equal(t, revoker.Len(), 2)
+	})
+	t.Run("Prune", func(t *testing.T) {
+		revoker.Clock = TimeMock{now.Add(2 * time.Hour)}
+		revoker.Prune()
+		assert.Equal(t, revoker.Len(), 0)
+	})
+}
@@ -0,0 +1,172 @@
+package store
+
+// Server-side session store
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hiroaki-yamamoto/gauth/clock"
+)
+
+// Session is a server-side session.
+type Session struct {
+	// ID is the opaque random ID of the session that the cookie holds.
+	ID string `json:"id"`
+	// UserID is the ID of the user of the session.
+	UserID string `json:"uid"`
+	// CreatedAt is the time the user logged in.
+	CreatedAt time.Time `json:"created_at"`
+	// ExpireAt is the expiration time of the session.
+	ExpireAt time.Time `json:"expire_at"`
+	// UserAgent is User-Agent header of the login request.
+	UserAgent string `json:"user_agent,omitempty"`
+	// RemoteAddr is the address of the client of the login request.
+	RemoteAddr string `json:"remote_addr,omitempty"`
+	// Tenant is the ID of the tenant that the session belongs to.
+	Tenant string `json:"tid,omitempty"`
+	// Family is the ID of the refresh token family of the session, which is
+	// revoked together with the session.
+	Family string `json:"fam,omitempty"`
+	// Data is the server-side data of the session. Call SessionStore.Save
+	// to persist the modification.
+	Data map[string]string `json:"data,omitempty"`
+}
+
+// clone returns a deep copy of the session.
+func (me *Session) clone() *Session {
+	session := *me
+	session.Data = maps.Clone(me.Data)
+	return &session
+}
+
+// SessionStore keeps the server-side sessions. It also records the logins
+// of the stateless JWTs so that they can be listed and revoked.
+type SessionStore interface {
+	// Save creates or replaces the session.
+	Save(session *Session) error
+	// Get returns the session identified by ID. ErrSessionNotFound is
+	// returned if the session doesn't exist or is expired. The returned
+	// session is a copy, so modifying it doesn't affect the store until
+	// Save is called.
+	Get(ID string) (*Session, error)
+	// Delete deletes the session identified by ID. Deleting a missing
+	// session is not an error.
+	Delete(ID string) error
+	// List returns the active sessions of the user identified by userID in
+	// the order of CreatedAt, and of ID for the sessions created at the same
+	// time.
+	List(userID string) ([]*Session, error)
+}
+
+// sortSessions sorts sessions in the order of CreatedAt, and of ID for the
+// sessions created at the same time.
+func sortSessions(sessions []*Session) {
+	slices.SortFunc(sessions, func(a, b *Session) int {
+		return cmp.Or(
+			a.CreatedAt.Compare(b.CreatedAt), strings.Compare(a.ID, b.ID),
+		)
+	})
+}
+
+// MemorySessionStore is an in-memory implementation of SessionStore. The
+// expired sessions are pruned on Save. It is safe for concurrent use, but
+// the sessions are lost on restart and are not shared between processes.
+type MemorySessionStore struct {
+	// Clock is used to determine whether a session is expired.
+	Clock clock.Time
+	// PruneInterval is the minimum interval between the prunings that Save
+	// performs. If this is zero, DefaultPruneInterval is used.
+	PruneInterval time.Duration
+	mutex         sync.RWMutex
+	sessions      map[string]*Session
+	nextPrune     time.Time
+}
+
+// NewMemorySessionStore creates a new empty MemorySessionStore.
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{
+		Clock:    clock.DefaultTime{},
+		sessions: map[string]*Session{},
+	}
+}
+
+// Save implements SessionStore.
+func (me *MemorySessionStore) Save(session *Session) error {
+	now := me.Clock.Now()
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	if !now.Before(me.nextPrune) {
+		me.prune(now)
+	}
+	me.sessions[session.ID] = session.clone()
+	return nil
+}
+
+// Get implements SessionStore.
+func (me *MemorySessionStore) Get(ID string) (*Session, error) {
+	now := me.Clock.Now()
+	me.mutex.RLock()
+	defer me.mutex.RUnlock()
+	session, ok := me.sessions[ID]
+	if !ok || !now.Before(session.ExpireAt) {
+		return nil, ErrSessionNotFound
+	}
+	return session.clone(), nil
+}
+
+// Delete implements SessionStore.
+func (me *MemorySessionStore) Delete(ID string) error {
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	delete(me.sessions, ID)
+	return nil
+}
+
+// List implements SessionStore.
+func (me *MemorySessionStore) List(userID string) ([]*Session, error) {
+	now := me.Clock.Now()
+	me.mutex.RLock()
+	defer me.mutex.RUnlock()
+	var sessions []*Session
+	for _, session := range me.sessions {
+		if session.UserID == userID && now.Before(session.ExpireAt) {
+			sessions = append(sessions, session.clone())
+		}
+	}
+	sortSessions(sessions)
+	return sessions, nil
+}
+
+// Prune removes the expired sessions.
+func (me *MemorySessionStore) Prune() {
+	now := me.Clock.Now()
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	me.prune(now)
+}
+
+// Len returns the number of the sessions including the expired ones that
+// are not pruned yet.
+func (me *MemorySessionStore) Len() int {
+	me.mutex.RLock()
+	defer me.mutex.RUnlock()
+	return len(me.sessions)
+}
+
+func (me *MemorySessionStore) prune(now time.Time) {
+	for ID, session := range me.sessions {
+		if !now.Before(session.ExpireAt) {
+			delete(me.sessions, ID)
+		}
+	}
+	interval := me.PruneInterval
+	if interval <= 0 {
+		interval = DefaultPruneInterval
+	}
+	me.nextPrune = now.Add(interval)
+}
@@ -0,0 +1,75 @@
+package store_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hiroaki-yamamoto/gauth/store"
+	"gotest.tools/v3/assert"
+)
+
+// Server-side session store test
+
+func TestMemorySessionStore(t *testing.T) {
+	now := time.Now().UTC()
+	st := store.NewMemorySessionStore()
+	st.Clock = TimeMock{now}
+	session := &store.Session{
+		ID: "session", UserID: "user", CreatedAt: now,
+		ExpireAt: now.Add(time.Hour),
+		Data:     map[string]string{"theme": "dark"},
+	}
+	assert.NilError(t, st.Save(session))
+
+	t.Run("Get", func(t *testing.T) {
+		got, err := st.Get("session")
+		assert.NilError(t, err)
+		assert.DeepEqual(t, got, session)
+	})
+	t.Run("Copy", func(t *testing.T) {
+		got, err := st.Get("session")
+		assert.NilError(t, err)
+		got.Data["theme"] = "light"
+		got, err = st.Get("session")
+		assert.NilError(t, err)
+		assert.Equal(t, got.Data["theme"], "dark")
+	})
+	t.Run("Not found", func(t *testing.T) {
+		_, err := st.Get("unknown")
+		assert.ErrorIs(t, err, store.ErrSessionNotFound)
+	})
+	t.Run("Expired", func(t *testing.T) {
+		assert.NilError(t, st.Save(&store.Session{ID: "expired", ExpireAt: now}))
+		_, err := st.Get("expired")
+		assert.ErrorIs(t, err, store.ErrSessionNotFound)
+		st.Prune()
+		assert.Equal(t, st.Len(), 1)
+	})
+	t.Run("List", func(t *testing.T) {
+		older := &store.Session{
+			ID: "older", UserID: "user", CreatedAt: now.Add(-time.Minute),
+			ExpireAt: now.Add(time.Hour),
+		}
+		assert.NilError(t, st.Save(older))
+		// Created at the same time as session, and listed before it by ID.
+		another := &store.Session{
+			ID: "another", UserID: "user", CreatedAt: now,
+			ExpireAt: now.Add(time.Hour),
+		}
+		assert.NilError(t, st.Save(another))
+		assert.NilError(t, st.Save(&store.Session{
+			ID: "other", UserID: "other", ExpireAt: now.Add(time.Hour),
+		}))
+		sessions, err := st.List("user")
+		assert.NilError(t, err)
+		assert.DeepEqual(t, sessions, []*store.Session{older, another, session})
+		assert.NilError(t, st.Delete("older"))
+		assert.NilError(t, st.Delete("another"))
+	})
+	t.Run("Delete", func(t *testing.T) {
+		assert.NilError(t, st.Delete("session"))
+		_, err := st.Get("session")
+		assert.ErrorIs(t, err, store.ErrSessionNotFound)
+		assert.NilError(t, st.Delete("session"))
+	})
+}
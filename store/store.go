@@ -0,0 +1,22 @@
+// Package store provides the server-side state that gauth needs on top of
+// stateless JWTs, such as the refresh token families, the revoked tokens and
+// the server-side sessions.
+//
+// Each kind of state is described by an interface so that it can be backed
+// by any storage. This package also ships in-memory implementations that are
+// suitable for tests and single-process deployments.
+package store
+
+import "errors"
+
+var (
+	// ErrReused is returned when a refresh token that was already exchanged
+	// is presented again. The whole token family is revoked in this case.
+	ErrReused = errors.New("refresh token is reused")
+	// ErrUnknownFamily is returned when the token family is revoked, expired
+	// or has never been issued.
+	ErrUnknownFamily = errors.New("unknown refresh token family")
+	// ErrSessionNotFound is returned when the session is deleted, expired
+	// or has never been created.
+	ErrSessionNotFound = errors.New("session not found")
+)